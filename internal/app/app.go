@@ -13,6 +13,7 @@ import (
 	"order-service0/internal/repository/cache"
 	"order-service0/internal/repository/postgres"
 	"order-service0/internal/usecase"
+	"os"
 
 	"time"
 
@@ -21,11 +22,20 @@ import (
 	"github.com/pkg/errors"
 )
 
+const warmUpPageSize = 500
+
 type App struct {
 	config        *config.Config
 	httpServer    *http.Server
 	kafkaConsumer *kafkaDelivery.OrderConsumer
+	statusEvents  *kafkaDelivery.StatusEventProducer
+	cache         usecase.Cache
+	snapshotter   *cache.Snapshotter
 	db            *sql.DB
+
+	// stopConsumer отменяет контекст consumer, consumerDone закрывается после его остановки
+	stopConsumer context.CancelFunc
+	consumerDone chan struct{}
 }
 
 func NewApp(cfg *config.Config) *App {
@@ -55,38 +65,175 @@ func (a *App) initDB() error {
 }
 
 func (a *App) initServices() (*httpDelivery.OrderHandler, error) {
-	orderRepo := postgres.NewOrderRepository(a.db)
-	cacheRepo := cache.NewInMemoryCache()
-
-	ctx := context.Background()
-	orders, err := orderRepo.GetAll(ctx)
+	orderRepo := postgres.NewOrderRepository(a.db, postgres.ConflictPolicy(a.config.Database.ConflictPolicy))
+	cacheRepo, err := a.newCache()
 	if err != nil {
-		log.Printf("Warning: Failed to restore cache from database: %v", err)
-	} else {
-		cacheMap := make(map[string]*entities.Order)
-		for _, order := range orders {
-			cacheMap[order.OrderUID] = order
+		return nil, fmt.Errorf("failed to init cache: %w", err)
+	}
+	a.cache = cacheRepo
+
+	if !a.restoreCacheSnapshot(cacheRepo) {
+		a.warmUpCache(context.Background(), orderRepo, cacheRepo, a.config.Cache.WarmUpLimit)
+	}
+	if a.config.Cache.SnapshotPath != "" {
+		a.snapshotter = cache.StartSnapshotter(cacheRepo, a.config.Cache.SnapshotPath,
+			time.Duration(a.config.Cache.SnapshotInterval)*time.Second)
+	}
+
+	var statusEvents usecase.StatusEventPublisher
+	if a.config.Kafka.StatusTopic != "" {
+		a.statusEvents, err = kafkaDelivery.NewStatusEventProducer(a.config.Kafka)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create status event producer: %w", err)
 		}
-		cacheRepo.Restore(cacheMap)
-		log.Printf("Restored %d orders to cache", len(orders))
+		statusEvents = a.statusEvents
+	} else {
+		log.Println("Warning: Kafka status topic is not configured, status changes are not published")
 	}
 
-	orderUseCase := usecase.NewOrderUseCase(orderRepo, cacheRepo)
+	orderUseCase := usecase.NewOrderUseCase(orderRepo, cacheRepo,
+		time.Duration(*a.config.Cache.NegativeTTL)*time.Second, statusEvents)
 
-	a.kafkaConsumer = kafkaDelivery.NewOrderConsumer(
-		a.config.Kafka.Brokers,
-		a.config.Kafka.Topic,
-		a.config.Kafka.GroupID,
-		orderUseCase,
-	)
+	kafkaConsumer, err := kafkaDelivery.NewOrderConsumer(a.config.Kafka, orderUseCase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	if a.config.Kafka.DLQTopic == "" {
+		log.Println("Warning: Kafka dead-letter topic is not configured")
+	}
+	a.kafkaConsumer = kafkaConsumer
 
 	return httpDelivery.NewOrderHandler(orderUseCase), nil
 }
 
+// newCache создает кэш заказов согласно cache.backend: локальный в памяти,
+// общий в Redis или двухуровневый (локальный перед Redis)
+func (a *App) newCache() (usecase.Cache, error) {
+	cfg := a.config.Cache
+	ttl := time.Duration(cfg.TTL) * time.Second
+
+	newLocal := func() usecase.Cache {
+		opts := cache.Options{
+			MaxSize:         cfg.MaxEntries,
+			TTL:             ttl,
+			CleanupInterval: time.Duration(cfg.CleanupInterval) * time.Second,
+			EvictionPolicy:  cfg.EvictionPolicy,
+			Shards:          cfg.Shards,
+		}
+		if opts.Shards > 1 {
+			return cache.NewShardedCache(opts)
+		}
+		return cache.NewInMemoryCache(opts)
+	}
+	if cfg.Backend == "memory" {
+		return newLocal(), nil
+	}
+
+	remote, err := cache.NewRedisCache(cache.RedisOptions{
+		Addr:      cfg.Redis.Addr,
+		Password:  cfg.Redis.Password,
+		DB:        cfg.Redis.DB,
+		KeyPrefix: cfg.Redis.KeyPrefix,
+		TTL:       ttl,
+		Timeout:   time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Backend == "redis" {
+		return remote, nil
+	}
+	return cache.NewTieredCache(newLocal(), remote), nil
+}
+
+// restoreCacheSnapshot загружает кэш из файла снимка. Возвращает false, если снимки
+// отключены или файл отсутствует, поврежден либо устарел, — тогда кэш прогревается из базы.
+func (a *App) restoreCacheSnapshot(cacheRepo usecase.Cache) bool {
+	path := a.config.Cache.SnapshotPath
+	if path == "" {
+		return false
+	}
+
+	start := time.Now()
+	orders, createdAt, err := cache.LoadSnapshot(path, time.Duration(a.config.Cache.SnapshotMaxAge)*time.Second)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("Cache snapshot %s not found, warming up from database", path)
+		} else {
+			log.Printf("Warning: Cannot use cache snapshot %s, warming up from database: %v", path, err)
+		}
+		return false
+	}
+
+	cacheRepo.Restore(orders)
+	log.Printf("Restored %d orders to cache from snapshot taken at %s in %v",
+		len(orders), createdAt.Format(time.RFC3339), time.Since(start))
+	return true
+}
+
+// warmUpCache постранично загружает в кэш последние limit заказов по дате создания
+func (a *App) warmUpCache(ctx context.Context, orderRepo usecase.OrderRepository, cacheRepo usecase.Cache, limit int) {
+	start := time.Now()
+	loaded := 0
+	err := orderRepo.StreamRecent(ctx, limit, warmUpPageSize, func(orders []*entities.Order) error {
+		cacheRepo.SetMany(orders)
+		loaded += len(orders)
+		log.Printf("Cache warm-up: loaded %d/%d orders", loaded, limit)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to warm up cache from database after %d orders: %v", loaded, err)
+		return
+	}
+	log.Printf("Restored %d orders to cache in %v", loaded, time.Since(start))
+}
+
+// newAuthenticator создает аутентификатор по токенам из http.auth
+func (a *App) newAuthenticator() *httpDelivery.Authenticator {
+	tokens := make(map[string]httpDelivery.Caller, len(a.config.HTTP.Auth.Tokens))
+	for _, t := range a.config.HTTP.Auth.Tokens {
+		tokens[t.Token] = httpDelivery.Caller{Name: t.Name, Role: httpDelivery.Role(t.Role)}
+	}
+	if len(tokens) == 0 {
+		log.Println("Warning: HTTP access tokens are not configured, only public order lookups are available")
+	}
+	return httpDelivery.NewAuthenticator(tokens)
+}
+
 func (a *App) initHTTPServer(orderHandler *httpDelivery.OrderHandler) {
 	router := mux.NewRouter()
+	router.Use(a.newAuthenticator().Middleware, httpDelivery.ChangeSourceMiddleware)
+	writer := func(h http.HandlerFunc) http.HandlerFunc {
+		return httpDelivery.RequireRole(httpDelivery.RoleWriter, h)
+	}
+	support := func(h http.HandlerFunc) http.HandlerFunc {
+		return httpDelivery.RequireRole(httpDelivery.RoleSupport, h)
+	}
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return httpDelivery.RequireRole(httpDelivery.RoleAdmin, h)
+	}
+
+	// Без токена доступен только поиск заказа по известному идентификатору, трек-номеру или транзакции
 	router.HandleFunc("/order/{id}", orderHandler.GetOrderByUID).Methods("GET")
+	router.HandleFunc("/orders/by-track/{track}", orderHandler.GetOrderByTrackNumber).Methods("GET")
+	router.HandleFunc("/orders/by-transaction/{tx}", orderHandler.GetOrderByTransaction).Methods("GET")
 	router.HandleFunc("/", orderHandler.ServeStatic).Methods("GET")
+
+	router.HandleFunc("/orders", support(orderHandler.ListOrders)).Methods("GET")
+
+	// Создавать заказы могут интеграции и тестировщики с ролью writer: удаление, стирание
+	// данных и чтение полных персональных данных им недоступны
+	router.HandleFunc("/orders", writer(orderHandler.CreateOrder)).Methods("POST")
+
+	// Изменения заказов и история версий с полными персональными данными доступны только администраторам
+	router.HandleFunc("/orders/{id}/status", admin(orderHandler.ChangeOrderStatus)).Methods("PATCH")
+	router.HandleFunc("/orders/{id}", admin(orderHandler.DeleteOrder)).Methods("DELETE")
+	router.HandleFunc("/orders/{id}/history", admin(orderHandler.GetOrderHistory)).Methods("GET")
+	router.HandleFunc("/orders/{id}/versions/{n}", admin(orderHandler.GetOrderVersion)).Methods("GET")
+	router.HandleFunc("/customers/{id}/erasure", admin(orderHandler.EraseCustomer)).Methods("POST")
+
+	adminHandler := httpDelivery.NewAdminHandler(a.cache)
+	router.HandleFunc("/admin/cache/stats", admin(adminHandler.CacheStats)).Methods("GET")
 	router.PathPrefix("/static/").Handler(
 		http.StripPrefix("/static/", http.FileServer(http.Dir("./web/static/"))),
 	)
@@ -104,23 +251,41 @@ func (a *App) Run() error {
 	if err := a.initDB(); err != nil {
 		return fmt.Errorf("failed to init database: %w", err)
 	}
-	defer a.db.Close()
 
 	orderHandler, err := a.initServices()
 	if err != nil {
+		a.db.Close()
 		return fmt.Errorf("failed to init services: %w", err)
 	}
 
 	a.initHTTPServer(orderHandler)
 
-	ctx := context.Background()
-	go a.kafkaConsumer.Start(ctx)
+	// База и кэш закрываются в Stop, после остановки consumer
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopConsumer = cancel
+	a.consumerDone = make(chan struct{})
+	consumerErr := make(chan error, 1)
+	go func() {
+		defer close(a.consumerDone)
+		if err := a.kafkaConsumer.Start(ctx); err != nil {
+			consumerErr <- err
+		}
+	}()
 
-	log.Printf("Server starting on port %s", a.config.HTTP.Port)
-	if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return fmt.Errorf("failed to start HTTP server: %w", err)
-	}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s", a.config.HTTP.Port)
+		serverErr <- a.httpServer.ListenAndServe()
+	}()
 
+	select {
+	case err := <-serverErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to start HTTP server: %w", err)
+		}
+	case err := <-consumerErr:
+		return err
+	}
 	return nil
 }
 
@@ -134,12 +299,40 @@ func (a *App) Stop() {
 		}
 	}
 
+	// Воркеры consumer должны завершить текущие сообщения до закрытия кэша и базы
+	if a.stopConsumer != nil {
+		a.stopConsumer()
+		select {
+		case <-a.consumerDone:
+		case <-ctx.Done():
+			log.Println("Kafka consumer did not stop in time")
+		}
+	}
+
 	if a.kafkaConsumer != nil {
 		if err := a.kafkaConsumer.Close(); err != nil {
 			log.Printf("Kafka consumer close error: %v", err)
 		}
 	}
 
+	if a.statusEvents != nil {
+		if err := a.statusEvents.Close(); err != nil {
+			log.Printf("Status event producer close error: %v", err)
+		}
+	}
+
+	if a.snapshotter != nil {
+		if err := a.snapshotter.Stop(); err != nil {
+			log.Printf("Cache snapshot save error: %v", err)
+		}
+	}
+
+	if a.cache != nil {
+		if err := a.cache.Close(); err != nil {
+			log.Printf("Cache close error: %v", err)
+		}
+	}
+
 	if a.db != nil {
 		if err := a.db.Close(); err != nil {
 			log.Printf("Database close error: %v", err)
@@ -10,6 +10,7 @@ type Config struct {
 	HTTP     HTTPConfig     `yaml:"http"`
 	Database DatabaseConfig `yaml:"database"`
 	Kafka    KafkaConfig    `yaml:"kafka"`
+	Cache    CacheConfig    `yaml:"cache"`
 }
 
 type HTTPConfig struct {
@@ -17,15 +18,29 @@ type HTTPConfig struct {
 	ReadTimeout  int    `yaml:"read_timeout"`
 	WriteTimeout int    `yaml:"write_timeout"`
 	IdleTimeout  int    `yaml:"idle_timeout"`
+	// Auth задает токены доступа; запросы без токена получают публичное представление заказов
+	Auth AuthConfig `yaml:"auth"`
+}
+
+type AuthConfig struct {
+	Tokens []AuthToken `yaml:"tokens"`
+}
+
+// AuthToken — bearer-токен вызывающей стороны с ролью writer, support или admin
+type AuthToken struct {
+	Token string `yaml:"token"`
+	Name  string `yaml:"name"`
+	Role  string `yaml:"role"`
 }
 
 type DatabaseConfig struct {
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	DBName   string `yaml:"dbname"`
-	SSLMode  string `yaml:"sslmode"`
+	Host           string `yaml:"host"`
+	Port           string `yaml:"port"`
+	User           string `yaml:"user"`
+	Password       string `yaml:"password"`
+	DBName         string `yaml:"dbname"`
+	SSLMode        string `yaml:"sslmode"`
+	ConflictPolicy string `yaml:"conflict_policy"`
 }
 
 type KafkaConfig struct {
@@ -34,6 +49,64 @@ type KafkaConfig struct {
 	GroupID  string   `yaml:"group_id"`
 	MinBytes int      `yaml:"min_bytes"`
 	MaxBytes int      `yaml:"max_bytes"`
+	DLQTopic string   `yaml:"dlq_topic"`
+	// StatusTopic — топик событий смены статуса заказа; пустое значение отключает публикацию
+	StatusTopic           string          `yaml:"status_topic"`
+	MaxAttempts           int             `yaml:"max_attempts"`
+	RetryInitialBackoffMs int             `yaml:"retry_initial_backoff_ms"`
+	RetryMaxBackoffMs     int             `yaml:"retry_max_backoff_ms"`
+	RetryJitter           float64         `yaml:"retry_jitter"`
+	Workers               int             `yaml:"workers"`
+	MaxInFlight           int             `yaml:"max_in_flight"`
+	Ordering              string          `yaml:"ordering"`
+	BatchSize             int             `yaml:"batch_size"`
+	BatchTimeoutMs        int             `yaml:"batch_timeout_ms"`
+	StartOffset           string          `yaml:"start_offset"`
+	CommitIntervalMs      int             `yaml:"commit_interval_ms"`
+	SessionTimeoutMs      int             `yaml:"session_timeout_ms"`
+	RebalanceTimeoutMs    int             `yaml:"rebalance_timeout_ms"`
+	RebalanceStrategy     string          `yaml:"rebalance_strategy"`
+	TLS                   KafkaTLSConfig  `yaml:"tls"`
+	SASL                  KafkaSASLConfig `yaml:"sasl"`
+}
+
+type KafkaTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+type CacheConfig struct {
+	MaxEntries      int         `yaml:"max_entries"`
+	TTL             int         `yaml:"ttl"`
+	CleanupInterval int         `yaml:"cleanup_interval"`
+	EvictionPolicy  string      `yaml:"eviction_policy"`
+	WarmUpLimit     int         `yaml:"warmup_limit"`
+	Shards          int         `yaml:"shards"`
+	NegativeTTL     *int        `yaml:"negative_ttl"`
+	Backend         string      `yaml:"backend"`
+	Redis           RedisConfig `yaml:"redis"`
+	// SnapshotPath — файл снимка кэша; пустое значение отключает снимки
+	SnapshotPath     string `yaml:"snapshot_path"`
+	SnapshotInterval int    `yaml:"snapshot_interval"`
+	SnapshotMaxAge   int    `yaml:"snapshot_max_age"`
+}
+
+type RedisConfig struct {
+	Addr      string `yaml:"addr"`
+	Password  string `yaml:"password"`
+	DB        int    `yaml:"db"`
+	KeyPrefix string `yaml:"key_prefix"`
+	TimeoutMs int    `yaml:"timeout_ms"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -46,5 +119,149 @@ func Load(configPath string) (*Config, error) {
 	if err := yaml.NewDecoder(file).Decode(config); err != nil {
 		return nil, fmt.Errorf("error decoding config file: %w", err)
 	}
+	config.Cache.applyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 	return config, nil
 }
+
+// Validate проверяет значения конфигурации после применения значений по умолчанию
+func (c *Config) Validate() error {
+	if err := c.HTTP.Auth.Validate(); err != nil {
+		return fmt.Errorf("http.auth: %w", err)
+	}
+	if err := c.Cache.Validate(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	if err := c.Database.Validate(); err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	return nil
+}
+
+// Validate проверяет conflict_policy; пустое значение означает upsert
+func (c DatabaseConfig) Validate() error {
+	switch c.ConflictPolicy {
+	case "", "upsert", "reject":
+	default:
+		return fmt.Errorf("conflict_policy must be one of upsert, reject, got %q", c.ConflictPolicy)
+	}
+	return nil
+}
+
+func (c AuthConfig) Validate() error {
+	seen := make(map[string]bool, len(c.Tokens))
+	for i, t := range c.Tokens {
+		if t.Token == "" || t.Name == "" {
+			return fmt.Errorf("tokens[%d]: token and name are required", i)
+		}
+		if seen[t.Token] {
+			return fmt.Errorf("tokens[%d]: duplicate token for %q", i, t.Name)
+		}
+		seen[t.Token] = true
+		switch t.Role {
+		case "writer", "support", "admin":
+		default:
+			return fmt.Errorf("tokens[%d]: role must be one of writer, support, admin, got %q", i, t.Role)
+		}
+	}
+	return nil
+}
+
+const (
+	defaultCacheMaxEntries       = 1000
+	defaultCacheTTL              = 15 * 60 // 15 минут
+	defaultCacheCleanupInterval  = 60
+	defaultCacheEvictionPolicy   = "lru"
+	defaultCacheNegativeTTL      = 5
+	defaultCacheBackend          = "memory"
+	defaultCacheSnapshotInterval = 5 * 60  // 5 минут
+	defaultCacheSnapshotMaxAge   = 60 * 60 // 1 час
+)
+
+func (c *CacheConfig) applyDefaults() {
+	if c.MaxEntries == 0 {
+		c.MaxEntries = defaultCacheMaxEntries
+	}
+	if c.TTL == 0 {
+		c.TTL = defaultCacheTTL
+	}
+	if c.CleanupInterval == 0 {
+		c.CleanupInterval = defaultCacheCleanupInterval
+	}
+	if c.EvictionPolicy == "" {
+		c.EvictionPolicy = defaultCacheEvictionPolicy
+	}
+	if c.WarmUpLimit == 0 {
+		c.WarmUpLimit = c.MaxEntries
+	}
+	if c.Shards == 0 {
+		c.Shards = 1
+	}
+	// negative_ttl: 0 явно отключает негативное кэширование, поэтому по умолчанию
+	// заменяется только отсутствующее значение
+	if c.NegativeTTL == nil {
+		negativeTTL := defaultCacheNegativeTTL
+		c.NegativeTTL = &negativeTTL
+	}
+	if c.Backend == "" {
+		c.Backend = defaultCacheBackend
+	}
+	if c.SnapshotInterval == 0 {
+		c.SnapshotInterval = defaultCacheSnapshotInterval
+	}
+	if c.SnapshotMaxAge == 0 {
+		c.SnapshotMaxAge = defaultCacheSnapshotMaxAge
+	}
+}
+
+func (c CacheConfig) Validate() error {
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("max_entries must be positive, got %d", c.MaxEntries)
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive, got %d", c.TTL)
+	}
+	if c.CleanupInterval <= 0 {
+		return fmt.Errorf("cleanup_interval must be positive, got %d", c.CleanupInterval)
+	}
+	switch c.EvictionPolicy {
+	case "lru", "fifo":
+	default:
+		return fmt.Errorf("eviction_policy must be one of lru, fifo, got %q", c.EvictionPolicy)
+	}
+	if c.Shards <= 0 || c.Shards > c.MaxEntries {
+		return fmt.Errorf("shards must be between 1 and max_entries (%d), got %d", c.MaxEntries, c.Shards)
+	}
+	switch c.Backend {
+	case "memory":
+	case "redis", "tiered":
+		if c.Redis.Addr == "" {
+			return fmt.Errorf("redis.addr is required for %s backend", c.Backend)
+		}
+		if c.Redis.DB < 0 || c.Redis.TimeoutMs < 0 {
+			return fmt.Errorf("redis.db and redis.timeout_ms must not be negative")
+		}
+	default:
+		return fmt.Errorf("backend must be one of memory, redis, tiered, got %q", c.Backend)
+	}
+	if c.NegativeTTL != nil && *c.NegativeTTL < 0 {
+		return fmt.Errorf("negative_ttl must not be negative, got %d", *c.NegativeTTL)
+	}
+	if c.WarmUpLimit < 0 || c.WarmUpLimit > c.MaxEntries {
+		return fmt.Errorf("warmup_limit must be between 0 and max_entries (%d), got %d", c.MaxEntries, c.WarmUpLimit)
+	}
+	if c.SnapshotPath != "" {
+		if c.Backend != "memory" {
+			return fmt.Errorf("snapshot_path is supported only for memory backend, got %q", c.Backend)
+		}
+		if c.SnapshotInterval <= 0 {
+			return fmt.Errorf("snapshot_interval must be positive, got %d", c.SnapshotInterval)
+		}
+		if c.SnapshotMaxAge <= 0 {
+			return fmt.Errorf("snapshot_max_age must be positive, got %d", c.SnapshotMaxAge)
+		}
+	}
+	return nil
+}
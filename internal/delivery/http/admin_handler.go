@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+	"order-service0/internal/domain/entities"
+)
+
+// CacheStatsProvider отдает статистику кэша заказов
+type CacheStatsProvider interface {
+	Stats() entities.CacheStats
+}
+
+// AdminHandler обслуживает служебные эндпоинты
+type AdminHandler struct {
+	cache CacheStatsProvider
+}
+
+func NewAdminHandler(cache CacheStatsProvider) *AdminHandler {
+	return &AdminHandler{cache: cache}
+}
+
+// CacheStats возвращает статистику кэша в JSON
+func (h *AdminHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.cache.Stats())
+}
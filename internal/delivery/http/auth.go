@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+)
+
+// Role определяет, какое представление заказа получает вызывающая сторона
+type Role string
+
+const (
+	RolePublic  Role = "public"
+	RoleWriter  Role = "writer"
+	RoleSupport Role = "support"
+	RoleAdmin   Role = "admin"
+)
+
+// roleRank упорядочивает роли по правам. Writer может создавать заказы,
+// но читает их только в публичном представлении.
+var roleRank = map[Role]int{RolePublic: 0, RoleWriter: 1, RoleSupport: 2, RoleAdmin: 3}
+
+// allows сообщает, достаточно ли роли r для действий роли required
+func (r Role) allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+// Caller — аутентифицированная вызывающая сторона
+type Caller struct {
+	Name string
+	Role Role
+}
+
+var publicCaller = Caller{Role: RolePublic}
+
+type callerKey struct{}
+
+// callerFrom возвращает вызывающую сторону запроса; без токена это публичный вызов
+func callerFrom(ctx context.Context) Caller {
+	if caller, ok := ctx.Value(callerKey{}).(Caller); ok {
+		return caller
+	}
+	return publicCaller
+}
+
+// Authenticator определяет вызывающую сторону по заголовку Authorization: Bearer <token>
+type Authenticator struct {
+	// callers хранит вызывающие стороны по SHA-256 токена, чтобы время поиска
+	// не зависело от совпадения префикса токена
+	callers map[[sha256.Size]byte]Caller
+}
+
+// NewAuthenticator создает аутентификатор по соответствию токенов вызывающим сторонам
+func NewAuthenticator(tokens map[string]Caller) *Authenticator {
+	callers := make(map[[sha256.Size]byte]Caller, len(tokens))
+	for token, caller := range tokens {
+		callers[sha256.Sum256([]byte(token))] = caller
+	}
+	return &Authenticator{callers: callers}
+}
+
+// Middleware сохраняет вызывающую сторону в контексте запроса. Запрос без заголовка
+// Authorization считается публичным, а запрос с неизвестным токеном отклоняется с кодом 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		caller, known := a.callers[sha256.Sum256([]byte(token))]
+		if !ok || !known {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "invalid access token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, caller)))
+	})
+}
+
+// RequireRole пропускает к handler только вызывающие стороны с ролью не ниже role
+func RequireRole(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller := callerFrom(r.Context())
+		if caller.Role.allows(role) {
+			handler(w, r)
+			return
+		}
+		if caller.Role == RolePublic {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "access token is required")
+			return
+		}
+		writeJSONError(w, http.StatusForbidden, "forbidden", "role "+string(role)+" is required")
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"order-service0/internal/domain/apperrors"
+
+	"github.com/pkg/errors"
+)
+
+// errorResponse — тело ответа с ошибкой
+type errorResponse struct {
+	Error  string                 `json:"error"`
+	Code   string                 `json:"code"`
+	Fields []apperrors.FieldError `json:"fields,omitempty"`
+}
+
+// writeError сопоставляет ошибку предметной области с HTTP-статусом и пишет JSON-ответ.
+// Детали внутренних ошибок клиенту не передаются, а только логируются.
+func writeError(w http.ResponseWriter, err error) {
+	var validationErr *apperrors.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error:  apperrors.ErrValidation.Error(),
+			Code:   "validation_failed",
+			Fields: validationErr.Fields,
+		})
+	case errors.Is(err, apperrors.ErrValidation):
+		writeJSONError(w, http.StatusBadRequest, "validation_failed", err.Error())
+	case errors.Is(err, apperrors.ErrOrderNotFound):
+		writeJSONError(w, http.StatusNotFound, "not_found", apperrors.ErrOrderNotFound.Error())
+	case errors.Is(err, apperrors.ErrVersionNotFound):
+		writeJSONError(w, http.StatusNotFound, "not_found", apperrors.ErrVersionNotFound.Error())
+	case errors.Is(err, apperrors.ErrIdempotencyKeyReused):
+		writeJSONError(w, http.StatusUnprocessableEntity, "idempotency_key_reused", apperrors.ErrIdempotencyKeyReused.Error())
+	case errors.Is(err, apperrors.ErrInvalidTransition):
+		writeJSONError(w, http.StatusConflict, "invalid_transition", err.Error())
+	case errors.Is(err, apperrors.ErrConflict):
+		writeJSONError(w, http.StatusConflict, "conflict", apperrors.ErrConflict.Error())
+	case errors.Is(err, apperrors.ErrUnavailable):
+		log.Printf("Service unavailable: %v", err)
+		writeJSONError(w, http.StatusServiceUnavailable, "unavailable", apperrors.ErrUnavailable.Error())
+	default:
+		log.Printf("Internal error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal", "internal server error")
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Error: message, Code: code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
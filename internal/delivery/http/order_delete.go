@@ -0,0 +1,45 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"order-service0/internal/domain/apperrors"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// DeleteOrder обрабатывает DELETE /orders/{id}. Заказ помечается удаленным и
+// больше не возвращается; история его версий остается доступной.
+func (h *OrderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	if err := h.orderUseCase.DeleteOrder(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// eraseCustomerRequest — тело запроса POST /customers/{id}/erasure
+type eraseCustomerRequest struct {
+	Reason string `json:"reason"`
+}
+
+// EraseCustomer обрабатывает POST /customers/{id}/erasure: стирает имя, телефон, адрес
+// и email получателя во всех заказах покупателя и возвращает запись аудита.
+// Инициатором в записи аудита указывается аутентифицированная вызывающая сторона.
+func (h *OrderHandler) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	var req eraseCustomerRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBodySize)).Decode(&req); err != nil {
+		writeError(w, errors.Wrap(apperrors.Validation(err), "failed to decode erasure request"))
+		return
+	}
+
+	erasure, err := h.orderUseCase.EraseCustomer(r.Context(), mux.Vars(r)["id"], callerFrom(r.Context()).Name, req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, erasure)
+}
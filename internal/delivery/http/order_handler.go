@@ -1,9 +1,14 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
 	"order-service0/internal/usecase"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -24,25 +29,107 @@ func (h *OrderHandler) GetOrderByUID(w http.ResponseWriter, r *http.Request) {
 	orderUID := vars["id"]
 
 	if orderUID == "" {
-		http.Error(w, `{"error": "Order ID is required"}`, http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "bad_request", "order ID is required")
 		return
 	}
 
 	order, err := h.orderUseCase.GetOrderByUID(r.Context(), orderUID)
 	if err != nil {
-		if errors.Is(err, errors.New("order not found")) {
-			http.Error(w, `{"error": "Order not found"}`, http.StatusNotFound)
-			return
-		}
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(order); err != nil {
-		http.Error(w, `{"error": "Failed to encode response"}`, http.StatusInternalServerError)
+	writeOrder(w, r, http.StatusOK, order)
+}
+
+// GetOrderByTrackNumber обрабатывает GET /orders/by-track/{track}
+func (h *OrderHandler) GetOrderByTrackNumber(w http.ResponseWriter, r *http.Request) {
+	h.getOrderBy(w, r, "track", h.orderUseCase.GetOrderByTrackNumber)
+}
+
+// GetOrderByTransaction обрабатывает GET /orders/by-transaction/{tx}
+func (h *OrderHandler) GetOrderByTransaction(w http.ResponseWriter, r *http.Request) {
+	h.getOrderBy(w, r, "tx", h.orderUseCase.GetOrderByTransaction)
+}
+
+func (h *OrderHandler) getOrderBy(w http.ResponseWriter, r *http.Request, param string,
+	get func(ctx context.Context, value string) (*entities.Order, error)) {
+	value := mux.Vars(r)[param]
+	if value == "" {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", param+" is required")
+		return
+	}
+
+	order, err := get(r.Context(), value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeOrder(w, r, http.StatusOK, order)
+}
+
+// Ограничения запроса POST /orders
+const (
+	maxOrderBodySize     = 1 << 20
+	maxIdempotencyKeyLen = 255
+)
+
+// CreateOrder обрабатывает POST /orders. Тело совпадает с сообщением Kafka. Повтор запроса
+// с тем же заголовком Idempotency-Key и тем же заказом возвращает тот же ответ 201.
+func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		writeError(w, apperrors.NewValidationError(apperrors.FieldError{
+			Field:   "Idempotency-Key",
+			Message: "must be at most " + strconv.Itoa(maxIdempotencyKeyLen) + " characters",
+		}))
+		return
+	}
+
+	var order entities.Order
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBodySize)).Decode(&order); err != nil {
+		writeError(w, errors.Wrap(apperrors.Validation(err), "failed to decode order"))
+		return
+	}
+
+	replayed, err := h.orderUseCase.SubmitOrder(r.Context(), &order, idempotencyKey)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Location", "/order/"+url.PathEscape(order.OrderUID))
+	writeOrder(w, r, http.StatusCreated, &order)
+}
+
+// changeStatusRequest — тело запроса PATCH /orders/{id}/status
+type changeStatusRequest struct {
+	Status entities.OrderStatus `json:"status"`
+	Reason string               `json:"reason"`
+}
+
+// ChangeOrderStatus обрабатывает PATCH /orders/{id}/status и возвращает обновленный заказ.
+// Неразрешенный переход отклоняется с кодом 409.
+func (h *OrderHandler) ChangeOrderStatus(w http.ResponseWriter, r *http.Request) {
+	orderUID := mux.Vars(r)["id"]
+
+	var req changeStatusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBodySize)).Decode(&req); err != nil {
+		writeError(w, errors.Wrap(apperrors.Validation(err), "failed to decode status change"))
+		return
+	}
+
+	order, err := h.orderUseCase.ChangeOrderStatus(r.Context(), orderUID, req.Status, req.Reason)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeOrder(w, r, http.StatusOK, order)
 }
 
 func (h *OrderHandler) ServeStatic(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ChangeSourceMiddleware сохраняет в контексте запроса его источник, который
+// записывается в историю версий измененных запросом заказов. Вызывающей стороной
+// считается имя из токена доступа, а для публичных запросов — адрес клиента.
+// Должен выполняться после Authenticator.Middleware.
+func ChangeSourceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := callerFrom(r.Context()).Name
+		if caller == "" {
+			caller = r.RemoteAddr
+		}
+		source := entities.ChangeSource{
+			Kind: entities.SourceHTTP,
+			HTTP: &entities.HTTPSource{Caller: caller, Method: r.Method, Path: r.URL.Path},
+		}
+		next.ServeHTTP(w, r.WithContext(entities.WithChangeSource(r.Context(), source)))
+	})
+}
+
+// GetOrderHistory обрабатывает GET /orders/{id}/history и возвращает версии заказа
+// с источниками и отличиями от предыдущих версий
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.orderUseCase.GetOrderHistory(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// GetOrderVersion обрабатывает GET /orders/{id}/versions/{n} и возвращает версию
+// вместе с принятыми данными и состоянием заказа после нее
+func (h *OrderHandler) GetOrderVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	number, err := strconv.Atoi(vars["n"])
+	if err != nil || number < 1 {
+		writeError(w, apperrors.NewValidationError(apperrors.FieldError{Field: "n", Message: "must be a positive integer"}))
+		return
+	}
+
+	version, err := h.orderUseCase.GetOrderVersion(r.Context(), vars["id"], number)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, version)
+}
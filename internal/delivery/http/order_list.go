@@ -0,0 +1,119 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+	"strconv"
+	"time"
+)
+
+// orderListResponse — страница списка заказов в представлении роли вызывающей
+// стороны; next_cursor передается в параметре cursor для получения следующей страницы
+type orderListResponse struct {
+	Orders     []interface{} `json:"orders"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// ListOrders обрабатывает GET /orders. Параметры: customer_id, track_number,
+// delivery_service, locale, provider, bank, currency, brand, nm_id, created_from и
+// created_to (RFC 3339), sort (asc|desc по date_created), limit и cursor.
+func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, err := h.orderUseCase.SearchOrders(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := orderListResponse{Orders: orderViews(callerFrom(r.Context()).Role, page.Orders)}
+	if page.Next != nil {
+		resp.NextCursor = encodeCursor(page.Next)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseOrderFilter собирает фильтр из параметров запроса; все ошибки разбора
+// возвращаются одной ошибкой валидации
+func parseOrderFilter(query url.Values) (entities.OrderFilter, error) {
+	filter := entities.OrderFilter{
+		CustomerID:      query.Get("customer_id"),
+		TrackNumber:     query.Get("track_number"),
+		DeliveryService: query.Get("delivery_service"),
+		Locale:          query.Get("locale"),
+		Provider:        query.Get("provider"),
+		Bank:            query.Get("bank"),
+		Currency:        query.Get("currency"),
+		Brand:           query.Get("brand"),
+		Sort:            query.Get("sort"),
+	}
+
+	var fields []apperrors.FieldError
+	parseInt := func(name string, dst *int) {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				fields = append(fields, apperrors.FieldError{Field: name, Message: "must be an integer"})
+				return
+			}
+			*dst = n
+		}
+	}
+	parseTime := func(name string, dst *time.Time) {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				fields = append(fields, apperrors.FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+				return
+			}
+			*dst = t.UTC()
+		}
+	}
+
+	parseInt("nm_id", &filter.NMID)
+	parseInt("limit", &filter.Limit)
+	parseTime("created_from", &filter.CreatedFrom)
+	parseTime("created_to", &filter.CreatedTo)
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			fields = append(fields, apperrors.FieldError{Field: "cursor", Message: "is malformed"})
+		} else {
+			filter.After = cursor
+		}
+	}
+
+	if len(fields) > 0 {
+		return filter, apperrors.NewValidationError(fields...)
+	}
+	return filter, nil
+}
+
+// encodeCursor кодирует курсор в непрозрачную для клиента строку
+func encodeCursor(cursor *entities.OrderCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*entities.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor entities.OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.OrderUID == "" {
+		return nil, apperrors.ErrValidation
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"net/http"
+	"order-service0/internal/domain/entities"
+	"strings"
+	"time"
+)
+
+// publicOrderView — представление для отслеживания заказа без аутентификации:
+// статус, товары и город доставки
+type publicOrderView struct {
+	OrderUID        string               `json:"order_uid"`
+	TrackNumber     string               `json:"track_number"`
+	Status          entities.OrderStatus `json:"status"`
+	StatusChangedAt time.Time            `json:"status_changed_at"`
+	Delivery        publicDeliveryView   `json:"delivery"`
+	Items           []entities.Item      `json:"items"`
+}
+
+type publicDeliveryView struct {
+	City string `json:"city"`
+}
+
+// supportOrderView — полный заказ с замаскированными телефоном и email получателя.
+// Поле Delivery перекрывает одноименное поле встроенного заказа при кодировании в JSON.
+type supportOrderView struct {
+	*entities.Order
+	Delivery supportDeliveryView `json:"delivery"`
+}
+
+type supportDeliveryView struct {
+	entities.Delivery
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+// orderView возвращает представление заказа для роли role; администратор получает заказ целиком
+func orderView(role Role, order *entities.Order) interface{} {
+	switch role {
+	case RoleAdmin:
+		return order
+	case RoleSupport:
+		return supportOrderView{
+			Order: order,
+			Delivery: supportDeliveryView{
+				Delivery: order.Delivery,
+				Phone:    maskPhone(order.Delivery.Phone),
+				Email:    maskEmail(order.Delivery.Email),
+			},
+		}
+	default:
+		items := order.Items
+		if items == nil {
+			items = []entities.Item{}
+		}
+		return publicOrderView{
+			OrderUID:        order.OrderUID,
+			TrackNumber:     order.TrackNumber,
+			Status:          order.Status,
+			StatusChangedAt: order.StatusChangedAt,
+			Delivery:        publicDeliveryView{City: order.Delivery.City},
+			Items:           items,
+		}
+	}
+}
+
+// orderViews возвращает представления списка заказов для роли role
+func orderViews(role Role, orders []*entities.Order) []interface{} {
+	views := make([]interface{}, len(orders))
+	for i, order := range orders {
+		views[i] = orderView(role, order)
+	}
+	return views
+}
+
+// maskPhone оставляет только последние 4 символа телефона
+func maskPhone(phone string) string {
+	const visible = 4
+	runes := []rune(phone)
+	if len(runes) <= visible {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
+
+// maskEmail оставляет первый символ имени и домен: ivan@example.com -> i***@example.com
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return strings.Repeat("*", len([]rune(email)))
+	}
+	first := []rune(email[:at])[0]
+	return string(first) + "***" + email[at:]
+}
+
+// writeOrder пишет заказ в представлении, соответствующем роли вызывающей стороны
+func writeOrder(w http.ResponseWriter, r *http.Request, status int, order *entities.Order) {
+	writeJSON(w, status, orderView(callerFrom(r.Context()).Role, order))
+}
@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"log"
+	"order-service0/internal/usecase"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+const defaultBatchTimeout = 500 * time.Millisecond
+
+// BatchConfig задает накопление сообщений перед сохранением одной транзакцией
+type BatchConfig struct {
+	// Size — максимальное число сообщений в пачке; значение больше 1 включает пакетный режим
+	Size int
+	// Timeout — сколько ждать добора пачки после получения первого сообщения
+	Timeout time.Duration
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultBatchTimeout
+	}
+	return c
+}
+
+func (c BatchConfig) enabled() bool {
+	return c.Size > 1
+}
+
+// runBatches накапливает сообщения в пачки и сохраняет каждую одной транзакцией.
+// Offset коммитятся только после фиксации транзакции пачки. offsetTracker общий для всех
+// пачек, поэтому коммиты партиции не обгоняют незавершенное сообщение из прошлой пачки.
+// Если сообщение не удалось ни обработать, ни перенести в DLQ, возвращает ошибку.
+func (c *OrderConsumer) runBatches(ctx context.Context) error {
+	tracker := newOffsetTracker()
+	for {
+		batch, ok := c.collectBatch(ctx)
+		if !ok {
+			return nil
+		}
+		if err := c.processBatch(ctx, tracker, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// collectBatch ждет первое сообщение, а затем добирает пачку до Size сообщений
+// или до истечения Timeout. Возвращает false, если consumer остановлен.
+func (c *OrderConsumer) collectBatch(ctx context.Context) ([]kafka.Message, bool) {
+	first, ok := c.fetch(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	batch := make([]kafka.Message, 0, c.batch.Size)
+	batch = append(batch, first)
+
+	fetchCtx, cancel := context.WithTimeout(ctx, c.batch.Timeout)
+	defer cancel()
+	for len(batch) < c.batch.Size {
+		msg, err := c.reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil, false
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			log.Printf("Error fetching message: %v", err)
+			continue
+		}
+		batch = append(batch, msg)
+	}
+	return batch, true
+}
+
+// processBatch сохраняет пачку и коммитит завершенные префиксы партиций. На первом
+// сообщении, которое не удалось обработать, останавливается и возвращает его ошибку:
+// его offset и следующие offset той же партиции не коммитятся.
+func (c *OrderConsumer) processBatch(ctx context.Context, tracker *offsetTracker, msgs []kafka.Message) error {
+	tracked := make([]*trackedMessage, len(msgs))
+	messages := make([]usecase.OrderMessage, len(msgs))
+	for i, msg := range msgs {
+		tracked[i] = tracker.add(msg)
+		messages[i] = usecase.OrderMessage{Value: msg.Value, Source: messageSource(msg)}
+	}
+
+	rejected, err := c.saveBatch(ctx, messages)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error saving batch of %d messages, falling back to one-by-one processing: %v", len(msgs), err)
+	}
+
+	var commits []kafka.Message
+	var failed error
+	for i, msg := range msgs {
+		switch {
+		case rejected[i] != nil:
+			failed = c.deadLetter(ctx, msg, rejected[i], 1)
+		case err != nil:
+			// Пачку сохранить не удалось: обрабатываем сообщения по одному,
+			// чтобы в DLQ попали только проблемные
+			failed = c.handleMessage(ctx, msg)
+		}
+		if failed != nil {
+			break
+		}
+		if commit, released := tracker.complete(tracked[i]); released > 0 {
+			commits = append(commits, commit)
+		}
+	}
+
+	if len(commits) > 0 {
+		if err := c.reader.CommitMessages(ctx, commits...); err != nil {
+			log.Printf("Error committing batch: %v", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return failed
+}
+
+// saveBatch сохраняет пачку, повторяя попытки при временных ошибках
+func (c *OrderConsumer) saveBatch(ctx context.Context, messages []usecase.OrderMessage) (map[int]error, error) {
+	for attempt := 1; ; attempt++ {
+		rejected, err := c.orderUseCase.ProcessOrderBatch(ctx, messages)
+		if err == nil || !usecase.IsTemporary(err) || attempt >= c.retry.MaxAttempts {
+			return rejected, err
+		}
+		log.Printf("Error saving batch (attempt %d/%d): %v", attempt, c.retry.MaxAttempts, err)
+		if !sleep(ctx, c.retry.Backoff(attempt)) {
+			return rejected, err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"order-service0/internal/config"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которыми помечается сообщение в dead-letter топике
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+	HeaderFailedAt          = "x-failed-at"
+)
+
+// writerBatchTimeout — сколько writer ждет добора пачки. WriteMessages синхронный,
+// поэтому значение по умолчанию kafka-go (1s) задерживало бы каждую публикацию.
+const writerBatchTimeout = 10 * time.Millisecond
+
+// DeadLetterProducer публикует необработанные сообщения в dead-letter топик
+type DeadLetterProducer struct {
+	writer *kafka.Writer
+}
+
+func NewDeadLetterProducer(cfg config.KafkaConfig) (*DeadLetterProducer, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.DLQTopic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: writerBatchTimeout,
+		RequiredAcks: kafka.RequireAll,
+		Transport:    transport,
+	}
+
+	return &DeadLetterProducer{writer: writer}, nil
+}
+
+// Publish отправляет исходное сообщение в DLQ вместе с причиной ошибки и числом попыток
+func (p *DeadLetterProducer) Publish(ctx context.Context, msg kafka.Message, cause error, attempts int) error {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	err := p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to publish message to dead-letter topic")
+	}
+	return nil
+}
+
+func (p *DeadLetterProducer) Close() error {
+	return p.writer.Close()
+}
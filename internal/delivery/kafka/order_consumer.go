@@ -2,8 +2,14 @@ package kafka
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log"
+	"order-service0/internal/config"
+	"order-service0/internal/domain/entities"
 	"order-service0/internal/usecase"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/segmentio/kafka-go"
@@ -11,53 +17,225 @@ import (
 
 type OrderConsumer struct {
 	reader       *kafka.Reader
+	dlq          *DeadLetterProducer
+	retry        RetryPolicy
+	pool         WorkerPoolConfig
+	batch        BatchConfig
 	orderUseCase usecase.OrderUseCase
 }
 
-func NewOrderConsumer(brokers []string, topic, groupID string, orderUseCase usecase.OrderUseCase) *OrderConsumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  groupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
-	})
+const (
+	defaultMinBytes = 10e3 // 10KB
+	defaultMaxBytes = 10e6 // 10MB
+)
+
+// NewOrderConsumer создает consumer заказов по конфигурации Kafka. Если dead-letter топик
+// не задан, сообщение с постоянной ошибкой останавливает consumer без коммита его offset.
+func NewOrderConsumer(cfg config.KafkaConfig, orderUseCase usecase.OrderUseCase) (*OrderConsumer, error) {
+	readerConfig, err := newReaderConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var dlq *DeadLetterProducer
+	if cfg.DLQTopic != "" {
+		if dlq, err = NewDeadLetterProducer(cfg); err != nil {
+			return nil, err
+		}
+	}
 
 	return &OrderConsumer{
-		reader:       reader,
+		reader: kafka.NewReader(readerConfig),
+		dlq:    dlq,
+		retry: RetryPolicy{
+			MaxAttempts:    cfg.MaxAttempts,
+			InitialBackoff: time.Duration(cfg.RetryInitialBackoffMs) * time.Millisecond,
+			MaxBackoff:     time.Duration(cfg.RetryMaxBackoffMs) * time.Millisecond,
+			Jitter:         cfg.RetryJitter,
+		}.withDefaults(),
+		pool: WorkerPoolConfig{
+			Workers:     cfg.Workers,
+			MaxInFlight: cfg.MaxInFlight,
+			Ordering:    cfg.Ordering,
+		}.withDefaults(),
+		batch: BatchConfig{
+			Size:    cfg.BatchSize,
+			Timeout: time.Duration(cfg.BatchTimeoutMs) * time.Millisecond,
+		}.withDefaults(),
 		orderUseCase: orderUseCase,
+	}, nil
+}
+
+func newReaderConfig(cfg config.KafkaConfig) (kafka.ReaderConfig, error) {
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return kafka.ReaderConfig{}, err
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:          cfg.Brokers,
+		Topic:            cfg.Topic,
+		GroupID:          cfg.GroupID,
+		MinBytes:         cfg.MinBytes,
+		MaxBytes:         cfg.MaxBytes,
+		CommitInterval:   time.Duration(cfg.CommitIntervalMs) * time.Millisecond,
+		SessionTimeout:   time.Duration(cfg.SessionTimeoutMs) * time.Millisecond,
+		RebalanceTimeout: time.Duration(cfg.RebalanceTimeoutMs) * time.Millisecond,
+		Dialer:           dialer,
 	}
+	if readerConfig.MinBytes <= 0 {
+		readerConfig.MinBytes = defaultMinBytes
+	}
+	if readerConfig.MaxBytes <= 0 {
+		readerConfig.MaxBytes = defaultMaxBytes
+	}
+
+	switch strings.ToLower(cfg.StartOffset) {
+	case "", "first", "earliest":
+		readerConfig.StartOffset = kafka.FirstOffset
+	case "last", "latest":
+		readerConfig.StartOffset = kafka.LastOffset
+	default:
+		return kafka.ReaderConfig{}, fmt.Errorf("unsupported Kafka start offset %q", cfg.StartOffset)
+	}
+
+	switch strings.ToLower(cfg.RebalanceStrategy) {
+	case "":
+	case "range":
+		readerConfig.GroupBalancers = []kafka.GroupBalancer{kafka.RangeGroupBalancer{}}
+	case "round_robin", "roundrobin":
+		readerConfig.GroupBalancers = []kafka.GroupBalancer{kafka.RoundRobinGroupBalancer{}}
+	default:
+		return kafka.ReaderConfig{}, fmt.Errorf("unsupported Kafka rebalance strategy %q", cfg.RebalanceStrategy)
+	}
+
+	if err := readerConfig.Validate(); err != nil {
+		return kafka.ReaderConfig{}, errors.Wrap(err, "invalid Kafka reader config")
+	}
+	return readerConfig, nil
+}
+
+// Start читает сообщения до отмены ctx. Возвращает ошибку, если consumer остановился
+// сам, потому что сообщение нельзя ни обработать, ни перенести в DLQ.
+func (c *OrderConsumer) Start(ctx context.Context) error {
+	var err error
+	if c.batch.enabled() {
+		log.Printf("Starting Kafka consumer in batch mode (up to %d messages, %v)...", c.batch.Size, c.batch.Timeout)
+		err = c.runBatches(ctx)
+	} else {
+		log.Printf("Starting Kafka consumer with %d worker(s)...", c.pool.Workers)
+		err = c.runWorkers(ctx)
+	}
+	if err != nil {
+		return errors.Wrap(err, "kafka consumer stopped")
+	}
+	log.Println("Stopping Kafka consumer...")
+	return nil
 }
 
-func (c *OrderConsumer) Start(ctx context.Context) {
-	log.Println("Starting Kafka consumer...")
+// fetch получает следующее сообщение. Возвращает false, если consumer остановлен.
+func (c *OrderConsumer) fetch(ctx context.Context) (kafka.Message, bool) {
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Stopping Kafka consumer...")
-			return
-		default:
-			msg, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				if errors.Is(err, context.Canceled) {
-					return
-				}
-				log.Printf("Error fetching message: %v", err)
-				continue
-			}
+		msg, err := c.reader.FetchMessage(ctx)
+		if err == nil {
+			return msg, true
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
+			return kafka.Message{}, false
+		}
+		log.Printf("Error fetching message: %v", err)
+	}
+}
+
+// handleMessage обрабатывает сообщение и возвращает nil, если его offset можно коммитить.
+// Временные ошибки повторяются с экспоненциальной паузой: при заданном DLQ до MaxAttempts
+// попыток, без DLQ — пока обработка не удастся или ctx не отменят. Постоянные ошибки
+// сразу уходят в DLQ. Ошибка возвращается при отмене ctx или если сообщение с постоянной
+// ошибкой некуда перенести.
+func (c *OrderConsumer) handleMessage(ctx context.Context, msg kafka.Message) error {
+	sourceCtx := entities.WithChangeSource(ctx, messageSource(msg))
+	for attempts := 1; ; attempts++ {
+		err := c.orderUseCase.ProcessOrderMessage(sourceCtx, msg.Value)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Error processing order message (partition %d, offset %d, attempt %d): %v",
+			msg.Partition, msg.Offset, attempts, err)
+
+		if !usecase.IsTemporary(err) || (c.dlq != nil && attempts >= c.retry.MaxAttempts) {
+			return c.deadLetter(ctx, msg, err, attempts)
+		}
+		if !sleep(ctx, c.retry.Backoff(attempts)) {
+			return ctx.Err()
+		}
+	}
+}
+
+// messageSource возвращает положение сообщения для истории версий заказа
+func messageSource(msg kafka.Message) entities.ChangeSource {
+	return entities.ChangeSource{
+		Kind:  entities.SourceKafka,
+		Kafka: &entities.KafkaSource{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset},
+	}
+}
 
-			if err := c.orderUseCase.ProcessOrderMessage(ctx, msg.Value); err != nil {
-				log.Printf("Error processing order message: %v", err)
-				continue
-			}
+// deadLetter переносит сообщение в DLQ, повторяя публикацию с паузой, пока она не удастся
+// или ctx не отменят. Без DLQ возвращает ошибку: пропустить сообщение молча нельзя.
+func (c *OrderConsumer) deadLetter(ctx context.Context, msg kafka.Message, cause error, attempts int) error {
+	if c.dlq == nil {
+		return errors.Wrapf(cause, "message (partition %d, offset %d) cannot be processed and dead-letter topic is not configured",
+			msg.Partition, msg.Offset)
+	}
 
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				log.Printf("Error committing message: %v", err)
-			}
+	for publishAttempt := 1; ; publishAttempt++ {
+		err := c.dlq.Publish(ctx, msg, cause, attempts)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Error sending message (partition %d, offset %d) to dead-letter topic (attempt %d): %v",
+			msg.Partition, msg.Offset, publishAttempt, err)
+		if !sleep(ctx, c.retry.Backoff(publishAttempt)) {
+			return ctx.Err()
 		}
 	}
+
+	log.Printf("Message (partition %d, offset %d) moved to dead-letter topic after %d attempt(s)",
+		msg.Partition, msg.Offset, attempts)
+	return nil
+}
+
+// stopCause возвращает ошибку, из-за которой consumer остановился сам; при отмене
+// родительского контекста возвращает nil
+func stopCause(ctx context.Context) error {
+	if err := context.Cause(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// sleep ждет d или отмены контекста. Возвращает false, если контекст отменен.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
 
 func (c *OrderConsumer) Close() error {
+	if c.dlq != nil {
+		if err := c.dlq.Close(); err != nil {
+			log.Printf("Dead-letter producer close error: %v", err)
+		}
+	}
 	return c.reader.Close()
 }
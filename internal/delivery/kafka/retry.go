@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// RetryPolicy описывает повторную обработку сообщения при временных ошибках
+type RetryPolicy struct {
+	// MaxAttempts — общее число попыток, включая первую
+	MaxAttempts int
+	// InitialBackoff — пауза перед второй попыткой, далее удваивается
+	InitialBackoff time.Duration
+	// MaxBackoff — верхняя граница паузы между попытками
+	MaxBackoff time.Duration
+	// Jitter — доля случайного разброса паузы в диапазоне [0, 1]
+	Jitter float64
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+// Backoff возвращает паузу перед попыткой с номером attempt+1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		delta := float64(backoff) * p.Jitter
+		backoff = time.Duration(float64(backoff) - delta + rand.Float64()*2*delta)
+	}
+	return backoff
+}
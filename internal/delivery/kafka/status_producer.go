@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"order-service0/internal/config"
+	"order-service0/internal/domain/entities"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderEventType — заголовок с типом события
+const HeaderEventType = "x-event-type"
+
+// EventStatusChanged — тип события смены статуса заказа
+const EventStatusChanged = "order.status_changed"
+
+// StatusEventProducer публикует события смены статуса заказа в отдельный топик.
+// Ключ сообщения — order_uid, поэтому события одного заказа попадают в одну партицию по порядку.
+type StatusEventProducer struct {
+	writer *kafka.Writer
+}
+
+func NewStatusEventProducer(cfg config.KafkaConfig) (*StatusEventProducer, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        cfg.StatusTopic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: writerBatchTimeout,
+		RequiredAcks: kafka.RequireAll,
+		Transport:    transport,
+	}
+
+	return &StatusEventProducer{writer: writer}, nil
+}
+
+func (p *StatusEventProducer) PublishStatusChange(ctx context.Context, change entities.OrderStatusChange) error {
+	value, err := json.Marshal(change)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal status change")
+	}
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(change.OrderUID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: HeaderEventType, Value: []byte(EventStatusChanged)}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to publish status change")
+	}
+	return nil
+}
+
+func (p *StatusEventProducer) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Режимы упорядочивания сообщений между воркерами
+const (
+	// OrderingByPartition сохраняет порядок обработки внутри партиции
+	OrderingByPartition = "partition"
+	// OrderingByKey сохраняет порядок только для сообщений с одинаковым ключом (order_uid)
+	OrderingByKey = "key"
+)
+
+const (
+	defaultWorkers     = 1
+	defaultMaxInFlight = 100
+)
+
+// WorkerPoolConfig задает параллельную обработку сообщений
+type WorkerPoolConfig struct {
+	// Workers — число горутин, обрабатывающих сообщения
+	Workers int
+	// MaxInFlight — сколько полученных, но еще не обработанных сообщений допускается одновременно
+	MaxInFlight int
+	// Ordering — OrderingByPartition или OrderingByKey
+	Ordering string
+}
+
+func (c WorkerPoolConfig) withDefaults() WorkerPoolConfig {
+	if c.Workers <= 0 {
+		c.Workers = defaultWorkers
+	}
+	if c.MaxInFlight <= 0 {
+		c.MaxInFlight = defaultMaxInFlight
+	}
+	if c.MaxInFlight < c.Workers {
+		c.MaxInFlight = c.Workers
+	}
+	if c.Ordering != OrderingByKey {
+		c.Ordering = OrderingByPartition
+	}
+	return c
+}
+
+// route выбирает воркер для сообщения так, чтобы сообщения одной партиции
+// (или одного ключа) всегда обрабатывались одним воркером последовательно
+func (c WorkerPoolConfig) route(msg kafka.Message) int {
+	if c.Ordering == OrderingByKey && len(msg.Key) > 0 {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		return int(h.Sum32() % uint32(c.Workers))
+	}
+	return msg.Partition % c.Workers
+}
+
+// offsetTracker отслеживает сообщения в обработке и определяет, до какого offset
+// можно коммитить партицию: только до сообщения, все предшественники которого завершены
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int][]*trackedMessage
+}
+
+// trackedMessage хранит только координаты сообщения, необходимые для коммита
+type trackedMessage struct {
+	msg  kafka.Message
+	done bool
+}
+
+// job — сообщение, переданное воркеру, вместе с его записью в offsetTracker
+type job struct {
+	msg     kafka.Message
+	tracked *trackedMessage
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int][]*trackedMessage)}
+}
+
+// add регистрирует полученное сообщение. Сообщения одной партиции должны добавляться
+// в порядке возрастания offset, как их отдает reader.
+func (t *offsetTracker) add(msg kafka.Message) *trackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm := &trackedMessage{msg: kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset}}
+	t.partitions[msg.Partition] = append(t.partitions[msg.Partition], tm)
+	return tm
+}
+
+// complete отмечает сообщение обработанным и убирает из отслеживания непрерывный
+// завершенный префикс партиции. Возвращает последнее сообщение префикса и число
+// убранных сообщений; 0 означает, что префикс не сдвинулся.
+func (t *offsetTracker) complete(tm *trackedMessage) (kafka.Message, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm.done = true
+
+	queue := t.partitions[tm.msg.Partition]
+	n := 0
+	for n < len(queue) && queue[n].done {
+		n++
+	}
+	if n == 0 {
+		return kafka.Message{}, 0
+	}
+
+	last := queue[n-1].msg
+	t.partitions[tm.msg.Partition] = queue[n:]
+	return last, n
+}
+
+// runWorkers читает сообщения и раздает их воркерам пула, коммитя offset
+// по мере завершения непрерывных префиксов партиций. Место в inFlight освобождается,
+// только когда сообщение закоммичено, поэтому offsetTracker не растет больше MaxInFlight.
+//
+// Если воркер не смог обработать сообщение (см. handleMessage), чтение прекращается,
+// а runWorkers возвращает эту ошибку после завершения остальных воркеров.
+func (c *OrderConsumer) runWorkers(parent context.Context) error {
+	ctx, stop := context.WithCancelCause(parent)
+	defer stop(nil)
+
+	pool := c.pool
+	tracker := newOffsetTracker()
+	inFlight := make(chan struct{}, pool.MaxInFlight)
+	queues := make([]chan job, pool.Workers)
+
+	var wg sync.WaitGroup
+	for i := range queues {
+		// Буфер воркера вмещает все сообщения в обработке, поэтому раздача не блокируется
+		queues[i] = make(chan job, pool.MaxInFlight)
+		wg.Add(1)
+		go func(queue <-chan job) {
+			defer wg.Done()
+			for j := range queue {
+				if err := c.handleMessage(ctx, j.msg); err != nil {
+					stop(err)
+					continue
+				}
+				for released := c.complete(ctx, tracker, j.tracked); released > 0; released-- {
+					<-inFlight
+				}
+			}
+		}(queues[i])
+	}
+
+	c.dispatch(ctx, tracker, inFlight, queues)
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	return stopCause(ctx)
+}
+
+// dispatch читает сообщения и раздает их воркерам, пока ctx не отменен
+func (c *OrderConsumer) dispatch(ctx context.Context, tracker *offsetTracker, inFlight chan struct{}, queues []chan job) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case inFlight <- struct{}{}:
+		}
+
+		msg, ok := c.fetch(ctx)
+		if !ok {
+			<-inFlight
+			return
+		}
+
+		queues[c.pool.route(msg)] <- job{msg: msg, tracked: tracker.add(msg)}
+	}
+}
+
+// complete отмечает сообщение обработанным, коммитит сдвинувшийся префикс партиции
+// и возвращает число сообщений, убранных из отслеживания
+func (c *OrderConsumer) complete(ctx context.Context, tracker *offsetTracker, tm *trackedMessage) int {
+	commit, released := tracker.complete(tm)
+	if released == 0 {
+		return 0
+	}
+	if err := c.reader.CommitMessages(ctx, commit); err != nil {
+		log.Printf("Error committing message (partition %d, offset %d): %v", commit.Partition, commit.Offset, err)
+	}
+	return released
+}
@@ -0,0 +1,83 @@
+package apperrors
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Базовые ошибки предметной области. Слои репозитория и бизнес-логики оборачивают их,
+// а транспортный слой сопоставляет с кодами ответа через errors.Is.
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+	ErrUnavailable   = errors.New("service temporarily unavailable")
+	// ErrIdempotencyKeyReused — ключ идемпотентности уже использован для другого запроса
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	// ErrInvalidTransition — переход заказа в запрошенный статус не разрешен
+	ErrInvalidTransition = errors.New("order status transition is not allowed")
+	// ErrStaleVersion — версия события не новее уже примененной к заказу
+	ErrStaleVersion = errors.New("event version is stale")
+	// ErrVersionNotFound — в истории заказа нет запрошенной версии
+	ErrVersionNotFound = errors.New("order version not found")
+)
+
+// FieldError описывает ошибку валидации конкретного поля
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError содержит все ошибки валидации и сопоставляется с ErrValidation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return ErrValidation.Error()
+	}
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, "field "+f.Field+": "+f.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Unavailable помечает err как временную недоступность хранилища
+func Unavailable(err error) error {
+	return &wrapped{kind: ErrUnavailable, err: err}
+}
+
+// Conflict помечает err как конфликт с уже сохраненными данными
+func Conflict(err error) error {
+	return &wrapped{kind: ErrConflict, err: err}
+}
+
+// Validation помечает err как ошибку валидации входных данных
+func Validation(err error) error {
+	return &wrapped{kind: ErrValidation, err: err}
+}
+
+// wrapped связывает исходную ошибку с одной из базовых ошибок
+type wrapped struct {
+	kind error
+	err  error
+}
+
+func (w *wrapped) Error() string {
+	return w.kind.Error() + ": " + w.err.Error()
+}
+
+func (w *wrapped) Unwrap() []error {
+	return []error{w.kind, w.err}
+}
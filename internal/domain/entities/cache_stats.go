@@ -0,0 +1,59 @@
+package entities
+
+// Причины вытеснения записей из кэша
+const (
+	// EvictionReasonCapacity — запись вытеснена при заполнении кэша
+	EvictionReasonCapacity = "capacity"
+	// EvictionReasonRestore — запись удалена при восстановлении кэша из другого источника
+	EvictionReasonRestore = "restore"
+)
+
+// CacheStats — снимок счетчиков кэша заказов
+type CacheStats struct {
+	Hits                uint64            `json:"hits"`
+	Misses              uint64            `json:"misses"`
+	HitRatio            float64           `json:"hit_ratio"`
+	Evictions           map[string]uint64 `json:"evictions"`
+	Expirations         uint64            `json:"expirations"`
+	Size                int               `json:"size"`
+	Capacity            int               `json:"capacity"`
+	MemoryEstimateBytes int64             `json:"memory_estimate_bytes"`
+}
+
+// Add суммирует счетчики other в s; используется для объединения статистики сегментов
+func (s *CacheStats) Add(other CacheStats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Expirations += other.Expirations
+	s.Size += other.Size
+	s.Capacity += other.Capacity
+	s.MemoryEstimateBytes += other.MemoryEstimateBytes
+	if s.Evictions == nil {
+		s.Evictions = make(map[string]uint64, len(other.Evictions))
+	}
+	for reason, n := range other.Evictions {
+		s.Evictions[reason] += n
+	}
+	s.HitRatio = hitRatio(s.Hits, s.Misses)
+}
+
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// NewCacheStats создает снимок и вычисляет долю попаданий
+func NewCacheStats(hits, misses uint64, evictions map[string]uint64, expirations uint64, size, capacity int, memory int64) CacheStats {
+	return CacheStats{
+		Hits:                hits,
+		Misses:              misses,
+		HitRatio:            hitRatio(hits, misses),
+		Evictions:           evictions,
+		Expirations:         expirations,
+		Size:                size,
+		Capacity:            capacity,
+		MemoryEstimateBytes: memory,
+	}
+}
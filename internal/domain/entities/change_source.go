@@ -0,0 +1,56 @@
+package entities
+
+import "context"
+
+// Виды источников изменения заказа
+const (
+	SourceKafka = "kafka"
+	SourceHTTP  = "http"
+)
+
+// ChangeSource описывает, откуда пришло изменение заказа; сохраняется в истории версий
+type ChangeSource struct {
+	Kind  string       `json:"kind"`
+	Kafka *KafkaSource `json:"kafka,omitempty"`
+	HTTP  *HTTPSource  `json:"http,omitempty"`
+}
+
+// KafkaSource — положение сообщения в топике
+type KafkaSource struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// HTTPSource — запрос, которым изменен заказ
+type HTTPSource struct {
+	Caller string `json:"caller"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type changeSourceKey struct{}
+
+type orderSourcesKey struct{}
+
+// WithChangeSource сохраняет источник изменений в контексте
+func WithChangeSource(ctx context.Context, source ChangeSource) context.Context {
+	return context.WithValue(ctx, changeSourceKey{}, source)
+}
+
+// WithOrderSources сохраняет в контексте отдельные источники для заказов пачки
+func WithOrderSources(ctx context.Context, sources map[string]ChangeSource) context.Context {
+	return context.WithValue(ctx, orderSourcesKey{}, sources)
+}
+
+// ChangeSourceFor возвращает источник изменения заказа orderUID: сначала из источников
+// пачки, затем общий источник контекста. Если источник не задан, возвращается пустое значение.
+func ChangeSourceFor(ctx context.Context, orderUID string) ChangeSource {
+	if sources, ok := ctx.Value(orderSourcesKey{}).(map[string]ChangeSource); ok {
+		if source, ok := sources[orderUID]; ok {
+			return source
+		}
+	}
+	source, _ := ctx.Value(changeSourceKey{}).(ChangeSource)
+	return source
+}
@@ -0,0 +1,23 @@
+package entities
+
+import "time"
+
+// ErasedValue заменяет персональные данные получателя при удалении данных покупателя
+const ErasedValue = "[erased]"
+
+// ErasedDeliveryFields — JSON-поля Delivery, которые стираются по запросу покупателя.
+// Город, регион и индекс остаются для отчетности.
+var ErasedDeliveryFields = []string{"name", "phone", "address", "email"}
+
+// CustomerErasure — запись аудита об удалении персональных данных покупателя.
+// Платежные данные заказов не изменяются и сохраняются для бухгалтерии.
+type CustomerErasure struct {
+	ID          int64        `json:"id"`
+	CustomerID  string       `json:"customer_id"`
+	RequestedBy string       `json:"requested_by"`
+	Reason      string       `json:"reason,omitempty"`
+	Source      ChangeSource `json:"source"`
+	RequestedAt time.Time    `json:"requested_at"`
+	// OrderUIDs — заказы покупателя, включая удаленные, в которых стерты данные
+	OrderUIDs []string `json:"order_uids"`
+}
@@ -19,6 +19,24 @@ type Order struct {
 	SMID              int       `json:"sm_id" validate:"required"`
 	DateCreated       time.Time `json:"date_created" validate:"required"`
 	OOFShard          string    `json:"oof_shard" validate:"required"`
+	// Статус и версия управляются сервисом: значения из тела заказа не принимаются
+	Status          OrderStatus `json:"status"`
+	StatusChangedAt time.Time   `json:"status_changed_at"`
+	// Version — версия последнего примененного события заказа
+	Version int64 `json:"version"`
+}
+
+// Clone возвращает глубокую копию заказа, изменение которой не затрагивает оригинал
+func (o *Order) Clone() *Order {
+	if o == nil {
+		return nil
+	}
+	clone := *o
+	if o.Items != nil {
+		clone.Items = make([]Item, len(o.Items))
+		copy(clone.Items, o.Items)
+	}
+	return &clone
 }
 
 type Delivery struct {
@@ -0,0 +1,40 @@
+package entities
+
+import "time"
+
+// Направление сортировки заказов по date_created
+const (
+	SortDesc = "desc"
+	SortAsc  = "asc"
+)
+
+// OrderCursor указывает на последний заказ страницы; следующая страница начинается после него
+type OrderCursor struct {
+	DateCreated time.Time `json:"date_created"`
+	OrderUID    string    `json:"order_uid"`
+}
+
+// OrderFilter задает условия поиска заказов. Пустые поля не участвуют в отборе,
+// Brand и NMID должны совпасть у одного и того же товара заказа.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	Provider        string
+	Bank            string
+	Currency        string
+	Brand           string
+	NMID            int
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	Sort            string
+	Limit           int
+	After           *OrderCursor
+}
+
+// OrderPage — страница результатов поиска; Next пуст, если страница последняя
+type OrderPage struct {
+	Orders []*Order
+	Next   *OrderCursor
+}
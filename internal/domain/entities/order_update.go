@@ -0,0 +1,21 @@
+package entities
+
+// OrderUpdate — частичное изменение заказа из события order.updated или order.cancelled.
+// Поля со значением nil не меняются; Items, если задан, заменяет весь список товаров.
+type OrderUpdate struct {
+	OrderUID        string    `json:"-"`
+	Version         int64     `json:"-"`
+	TrackNumber     *string   `json:"track_number,omitempty"`
+	DeliveryService *string   `json:"delivery_service,omitempty"`
+	Delivery        *Delivery `json:"delivery,omitempty"`
+	Payment         *Payment  `json:"payment,omitempty"`
+	Items           *[]Item   `json:"items,omitempty" validate:"omitempty,dive"`
+	// StatusChange заполняется при отмене заказа
+	StatusChange *OrderStatusChange `json:"-"`
+}
+
+// Empty сообщает, что обновление ничего не меняет
+func (u *OrderUpdate) Empty() bool {
+	return u.TrackNumber == nil && u.DeliveryService == nil && u.Delivery == nil &&
+		u.Payment == nil && u.Items == nil && u.StatusChange == nil
+}
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Виды изменений, сохраняемых в истории версий заказа
+const (
+	ChangeCreated  = "created"
+	ChangeReplaced = "replaced"
+	ChangeUpdated  = "updated"
+	ChangeStatus   = "status_changed"
+	ChangeDeleted  = "deleted"
+)
+
+// FieldChange — изменение одного поля заказа между соседними версиями.
+// Path записывается в JSON-нотации, например delivery.city или items[1].price.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// OrderVersion — запись истории заказа. Payload содержит принятые данные изменения,
+// Order — состояние заказа после него, Diff — отличия от предыдущей версии.
+// В списке истории Payload и Order не заполняются.
+type OrderVersion struct {
+	OrderUID   string          `json:"order_uid"`
+	Number     int             `json:"number"`
+	Change     string          `json:"change"`
+	Source     ChangeSource    `json:"source"`
+	ReceivedAt time.Time       `json:"received_at"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Order      *Order          `json:"order,omitempty"`
+	Diff       []FieldChange   `json:"diff"`
+}
@@ -2,7 +2,9 @@ package validator
 
 import (
 	"fmt"
+	"order-service0/internal/domain/apperrors"
 	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -25,18 +27,35 @@ func NewValidator() *CustomValidator {
 	return &CustomValidator{validator: v}
 }
 
+// ValidateStruct проверяет структуру и возвращает *apperrors.ValidationError
+// со всеми нарушенными правилами
 func (cv *CustomValidator) ValidateStruct(s interface{}) error {
 	if err := cv.validator.Struct(s); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			fields := make([]apperrors.FieldError, 0, len(validationErrors))
 			for _, fieldError := range validationErrors {
-				return fmt.Errorf("field %s: %s", fieldError.Field(), getValidationMessage(fieldError))
+				fields = append(fields, apperrors.FieldError{
+					Field:   fieldPath(fieldError),
+					Message: getValidationMessage(fieldError),
+				})
 			}
+			return apperrors.NewValidationError(fields...)
 		}
 		return err
 	}
 	return nil
 }
 
+// fieldPath возвращает путь к полю в JSON-нотации без имени корневой структуры,
+// например delivery.email или items[0].price
+func fieldPath(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
 func getValidationMessage(fieldError validator.FieldError) string {
 	switch fieldError.Tag() {
 	case "required":
@@ -1,133 +1,390 @@
 package cache
 
 import (
+	"container/list"
 	"sync"
 	"time"
 
 	"order-service0/internal/domain/entities"
 )
 
+// Политики вытеснения при заполнении кэша
+const (
+	// EvictionLRU вытесняет заказ, к которому дольше всего не обращались
+	EvictionLRU = "lru"
+	// EvictionFIFO вытесняет заказ, добавленный раньше остальных
+	EvictionFIFO = "fifo"
+)
+
+// Options задает параметры in-memory кэша; нулевые значения заменяются значениями по умолчанию
+type Options struct {
+	MaxSize         int
+	TTL             time.Duration
+	CleanupInterval time.Duration
+	EvictionPolicy  string
+	// Shards — число сегментов для NewShardedCache
+	Shards int
+}
+
+// promotionBufferSize — сколько обращений на чтение может ожидать переноса в голову LRU
+const promotionBufferSize = 64
+
+func (o Options) withDefaults() Options {
+	if o.MaxSize <= 0 {
+		o.MaxSize = 1000 // дефолтный размер
+	}
+	if o.TTL <= 0 {
+		o.TTL = 15 * time.Minute // дефолтное время жизни
+	}
+	if o.CleanupInterval <= 0 {
+		o.CleanupInterval = time.Minute
+	}
+	if o.EvictionPolicy != EvictionFIFO {
+		o.EvictionPolicy = EvictionLRU
+	}
+	if o.Shards <= 0 {
+		o.Shards = 1
+	}
+	return o
+}
+
+// inMemoryCache хранит заказы в map и двух двусвязных списках, поэтому Set, Get
+// и вытеснение выполняются за O(1):
+//   - evictList упорядочен по давности использования (LRU) или добавления (FIFO);
+//     вытесняется элемент из хвоста;
+//   - expiryList упорядочен по времени записи; так как TTL одинаков для всех записей,
+//     истекшие элементы всегда находятся в его хвосте.
+//
+// Get берет только блокировку на чтение: обращения к записям складываются в буфер
+// promotions и переносятся в голову LRU при следующей операции записи. При переполнении
+// буфера обращения отбрасываются, что лишь немного снижает точность LRU.
+//
+// indexes отображает вторичные ключи (трек-номер, номер транзакции) в order_uid
+// самого нового заказа с этим ключом среди находящихся в кэше.
 type inMemoryCache struct {
-	mu      sync.RWMutex
-	orders  map[string]*cacheEntry
-	maxSize int
-	ttl     time.Duration
+	mu             sync.RWMutex
+	orders         map[string]*cacheEntry
+	indexes        [indexCount]map[string]string
+	evictList      *list.List
+	expiryList     *list.List
+	maxSize        int
+	ttl            time.Duration
+	evictionPolicy string
+	promotions     chan *cacheEntry
+	janitor        *janitor
+	memoryBytes    int64
+	counters       cacheCounters
 }
 
 type cacheEntry struct {
+	orderUID   string
 	order      *entities.Order
 	expiresAt  time.Time
-	lastAccess time.Time
+	size       int64
+	evictElem  *list.Element
+	expiryElem *list.Element
 }
 
-func NewInMemoryCache(maxSize int, ttl time.Duration) *inMemoryCache {
-	if maxSize <= 0 {
-		maxSize = 1000 // дефолтный размер
-	}
-	if ttl <= 0 {
-		ttl = 15 * time.Minute // дефолтное время жизни
-	}
+func NewInMemoryCache(opts Options) *inMemoryCache {
+	opts = opts.withDefaults()
+	cache := newInMemoryCache(opts)
+	// Запускаем горутину для периодической очистки, она останавливается в Close
+	cache.janitor = startJanitor(opts.CleanupInterval, cache.cleanupExpired)
+	return cache
+}
+
+// newInMemoryCache создает кэш без фоновой очистки; используется и как сегмент shardedCache
+func newInMemoryCache(opts Options) *inMemoryCache {
 	cache := &inMemoryCache{
-		orders:  make(map[string]*cacheEntry),
-		maxSize: maxSize,
-		ttl:     ttl,
+		orders:         make(map[string]*cacheEntry),
+		evictList:      list.New(),
+		expiryList:     list.New(),
+		maxSize:        opts.MaxSize,
+		ttl:            opts.TTL,
+		evictionPolicy: opts.EvictionPolicy,
+		promotions:     make(chan *cacheEntry, promotionBufferSize),
 	}
-	// Запускаем горутину для периодической очистки
-	go cache.cleanupWorker()
+	cache.resetIndexes()
 	return cache
 }
 
+// Set сохраняет копию заказа, поэтому последующие изменения order вызывающим
+// кодом не попадают в кэш
 func (c *inMemoryCache) Set(orderUID string, order *entities.Order) {
+	order = order.Clone()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(orderUID, order, time.Now())
+}
+
+// SetMany добавляет заказы под одной блокировкой
+func (c *inMemoryCache) SetMany(orders []*entities.Order) {
+	clones := make([]*entities.Order, len(orders))
+	for i, order := range orders {
+		clones[i] = order.Clone()
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	now := time.Now()
+	for _, order := range clones {
+		c.set(order.OrderUID, order, now)
+	}
+}
+
+// set добавляет или обновляет запись; вызывается под блокировкой.
+// Сохраненный заказ больше не изменяется, наружу отдаются только его копии.
+func (c *inMemoryCache) set(orderUID string, order *entities.Order, now time.Time) {
+	c.applyPromotions()
+	size := entryOverhead + int64(len(orderUID)) + estimateOrderSize(order)
+	if entry, exists := c.orders[orderUID]; exists {
+		c.memoryBytes += size - entry.size
+		c.unindex(entry)
+		entry.order = order
+		c.index(entry)
+		entry.size = size
+		entry.expiresAt = now.Add(c.ttl)
+		c.evictList.MoveToFront(entry.evictElem)
+		c.expiryList.MoveToFront(entry.expiryElem)
+		return
+	}
+
 	// Если кэш заполнен, удаляем самый старый элемент
 	if len(c.orders) >= c.maxSize {
 		c.evictOldest()
 	}
-	c.orders[orderUID] = &cacheEntry{
-		order:      order,
-		expiresAt:  time.Now().Add(c.ttl),
-		lastAccess: time.Now(),
+	entry := &cacheEntry{
+		orderUID:  orderUID,
+		order:     order,
+		size:      size,
+		expiresAt: now.Add(c.ttl),
+	}
+	c.memoryBytes += size
+	entry.evictElem = c.evictList.PushFront(entry)
+	entry.expiryElem = c.expiryList.PushFront(entry)
+	c.orders[orderUID] = entry
+	c.index(entry)
+}
+
+// Delete удаляет заказ из кэша, например после его изменения в базе
+func (c *inMemoryCache) Delete(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applyPromotions()
+	if entry, exists := c.orders[orderUID]; exists {
+		c.remove(entry)
 	}
 }
 
+// Get возвращает копию заказа, которую вызывающий код может изменять
 func (c *inMemoryCache) Get(orderUID string) (*entities.Order, bool) {
 	c.mu.RLock()
+	defer c.mu.RUnlock()
 	entry, exists := c.orders[orderUID]
-	c.mu.RUnlock()
-	if !exists {
+	// Истекшие записи удаляет cleanupExpired
+	if !exists || time.Now().After(entry.expiresAt) {
+		c.counters.misses.Add(1)
 		return nil, false
 	}
-	// Проверяем не истек ли TTL
-	if time.Now().After(entry.expiresAt) {
-		c.mu.Lock()
-		delete(c.orders, orderUID)
-		c.mu.Unlock()
+	c.counters.hits.Add(1)
+	c.promote(entry)
+	return entry.order.Clone(), true
+}
+
+// GetByTrackNumber возвращает копию самого нового заказа с трек-номером trackNumber
+func (c *inMemoryCache) GetByTrackNumber(trackNumber string) (*entities.Order, bool) {
+	return c.getIndexed(indexTrackNumber, trackNumber)
+}
+
+// GetByTransaction возвращает копию самого нового заказа с транзакцией transaction
+func (c *inMemoryCache) GetByTransaction(transaction string) (*entities.Order, bool) {
+	return c.getIndexed(indexTransaction, transaction)
+}
+
+func (c *inMemoryCache) getIndexed(index secondaryIndex, value string) (*entities.Order, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry := c.lookupIndexed(index, value)
+	if entry == nil {
+		c.counters.misses.Add(1)
 		return nil, false
 	}
-	// Обновляем время последнего доступа
-	c.mu.Lock()
-	entry.lastAccess = time.Now()
-	c.mu.Unlock()
-	return entry.order, true
+	c.counters.hits.Add(1)
+	c.promote(entry)
+	return entry.order.Clone(), true
+}
+
+// findIndexed ищет действующую запись по вторичному ключу и возвращает копию заказа
+// вместе с записью; статистика обращений не обновляется
+func (c *inMemoryCache) findIndexed(index secondaryIndex, value string) (*entities.Order, *cacheEntry) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry := c.lookupIndexed(index, value)
+	if entry == nil {
+		return nil, nil
+	}
+	return entry.order.Clone(), entry
+}
+
+// lookupIndexed возвращает действующую запись по вторичному ключу; вызывается под блокировкой
+func (c *inMemoryCache) lookupIndexed(index secondaryIndex, value string) *cacheEntry {
+	entry, exists := c.orders[c.indexes[index][value]]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry
+}
+
+// promoteFound отмечает использование записи, найденной findIndexed. Между вызовами
+// блокировка освобождалась, поэтому запись ставится в буфер, только если она все еще
+// в кэше: элемент сброшенного Restore списка повредил бы новый список.
+func (c *inMemoryCache) promoteFound(entry *cacheEntry) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.orders[entry.orderUID] == entry {
+		c.promote(entry)
+	}
+}
+
+// promote отмечает использование записи без эксклюзивной блокировки; вызывается под
+// блокировкой на чтение, чтобы Restore не сбросил списки до постановки записи в буфер
+func (c *inMemoryCache) promote(entry *cacheEntry) {
+	if c.evictionPolicy == EvictionLRU {
+		select {
+		case c.promotions <- entry:
+		default:
+		}
+	}
+}
+
+// applyPromotions переносит в голову LRU записи, прочитанные после последней записи в кэш;
+// вызывается под блокировкой на запись
+func (c *inMemoryCache) applyPromotions() {
+	for {
+		select {
+		case entry := <-c.promotions:
+			// Для удаленной записи MoveToFront ничего не делает
+			c.evictList.MoveToFront(entry.evictElem)
+		default:
+			return
+		}
+	}
 }
 
 func (c *inMemoryCache) GetAll() map[string]*entities.Order {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	result := make(map[string]*entities.Order)
+	result := make(map[string]*entities.Order, len(c.orders))
 	now := time.Now()
 	for k, v := range c.orders {
 		if now.Before(v.expiresAt) {
-			result[k] = v.order
+			result[k] = v.order.Clone()
 		}
 	}
 	return result
 }
 
 func (c *inMemoryCache) Restore(orders map[string]*entities.Order) {
+	clones := make(map[string]*entities.Order, len(orders))
+	for k, v := range orders {
+		clones[k] = v.Clone()
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.orders = make(map[string]*cacheEntry)
-	for k, v := range orders {
-		c.orders[k] = &cacheEntry{
-			order:      v,
-			expiresAt:  time.Now().Add(c.ttl),
-			lastAccess: time.Now(),
+	// Буфер обращений нужно опустошить до сброса списков: элементы старого списка
+	// после Init считались бы принадлежащими новому
+	c.applyPromotions()
+	c.counters.restoreEvictions.Add(uint64(len(c.orders)))
+	c.orders = make(map[string]*cacheEntry, len(orders))
+	c.resetIndexes()
+	c.evictList.Init()
+	c.expiryList.Init()
+	c.memoryBytes = 0
+	now := time.Now()
+	for k, v := range clones {
+		if len(c.orders) >= c.maxSize {
+			break
 		}
+		c.set(k, v, now)
 	}
 }
 
+// evictOldest удаляет элемент из хвоста списка вытеснения
 func (c *inMemoryCache) evictOldest() {
-	var oldestKey string
-	var oldestTime time.Time
-	for k, v := range c.orders {
-		if oldestKey == "" || v.lastAccess.Before(oldestTime) {
-			oldestKey = k
-			oldestTime = v.lastAccess
+	if elem := c.evictList.Back(); elem != nil {
+		c.remove(elem.Value.(*cacheEntry))
+		c.counters.capacityEvictions.Add(1)
+	}
+}
+
+func (c *inMemoryCache) remove(entry *cacheEntry) {
+	c.evictList.Remove(entry.evictElem)
+	c.expiryList.Remove(entry.expiryElem)
+	c.unindex(entry)
+	delete(c.orders, entry.orderUID)
+	c.memoryBytes -= entry.size
+}
+
+func (c *inMemoryCache) resetIndexes() {
+	for i := range c.indexes {
+		c.indexes[i] = make(map[string]string)
+	}
+}
+
+// index добавляет вторичные ключи записи, если для них в кэше нет более нового заказа;
+// вызывается под блокировкой
+func (c *inMemoryCache) index(entry *cacheEntry) {
+	for i := secondaryIndex(0); i < indexCount; i++ {
+		value := i.value(entry.order)
+		if value == "" {
+			continue
+		}
+		if uid, ok := c.indexes[i][value]; ok && uid != entry.orderUID {
+			if other, ok := c.orders[uid]; ok && newerOrder(other.order, entry.order) {
+				continue
+			}
 		}
+		c.indexes[i][value] = entry.orderUID
 	}
-	if oldestKey != "" {
-		delete(c.orders, oldestKey)
+}
+
+// unindex удаляет вторичные ключи, указывающие на запись; вызывается под блокировкой
+func (c *inMemoryCache) unindex(entry *cacheEntry) {
+	for i := secondaryIndex(0); i < indexCount; i++ {
+		value := i.value(entry.order)
+		if c.indexes[i][value] == entry.orderUID {
+			delete(c.indexes[i], value)
+		}
 	}
 }
 
-func (c *inMemoryCache) cleanupWorker() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
-	for range ticker.C {
-		c.cleanupExpired()
+// Stats возвращает снимок счетчиков кэша
+func (c *inMemoryCache) Stats() entities.CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.counters.snapshot(len(c.orders), c.maxSize, c.memoryBytes)
+}
+
+// Close останавливает фоновую очистку кэша
+func (c *inMemoryCache) Close() error {
+	if c.janitor != nil {
+		c.janitor.stop()
 	}
+	return nil
 }
 
+// cleanupExpired удаляет истекшие записи с хвоста expiryList
 func (c *inMemoryCache) cleanupExpired() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	now := time.Now()
-	for k, v := range c.orders {
-		if now.After(v.expiresAt) {
-			delete(c.orders, k)
+	for elem := c.expiryList.Back(); elem != nil; elem = c.expiryList.Back() {
+		entry := elem.Value.(*cacheEntry)
+		if !now.After(entry.expiresAt) {
+			return
 		}
+		c.remove(entry)
+		c.counters.expirations.Add(1)
 	}
 }
-
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service0/internal/domain/entities"
+)
+
+// benchCache — общие методы сравниваемых реализаций кэша
+type benchCache interface {
+	Set(orderUID string, order *entities.Order)
+	Get(orderUID string) (*entities.Order, bool)
+}
+
+// scanCache воспроизводит прежнюю реализацию inMemoryCache: вытеснение просматривает
+// всю map под блокировкой на запись, поэтому Set заполненного кэша выполняется за O(n)
+type scanCache struct {
+	mu      sync.RWMutex
+	orders  map[string]*scanEntry
+	maxSize int
+	ttl     time.Duration
+}
+
+type scanEntry struct {
+	order      *entities.Order
+	expiresAt  time.Time
+	lastAccess time.Time
+}
+
+func newScanCache(maxSize int) *scanCache {
+	return &scanCache{orders: make(map[string]*scanEntry), maxSize: maxSize, ttl: time.Hour}
+}
+
+func (c *scanCache) Set(orderUID string, order *entities.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.orders[orderUID]; !exists && len(c.orders) >= c.maxSize {
+		c.evictOldest()
+	}
+	now := time.Now()
+	c.orders[orderUID] = &scanEntry{order: order, expiresAt: now.Add(c.ttl), lastAccess: now}
+}
+
+func (c *scanCache) Get(orderUID string) (*entities.Order, bool) {
+	c.mu.RLock()
+	entry, exists := c.orders[orderUID]
+	c.mu.RUnlock()
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	c.mu.Lock()
+	entry.lastAccess = time.Now()
+	c.mu.Unlock()
+	return entry.order, true
+}
+
+func (c *scanCache) evictOldest() {
+	var oldestKey string
+	var oldestTime time.Time
+	for k, v := range c.orders {
+		if oldestKey == "" || v.lastAccess.Before(oldestTime) {
+			oldestKey, oldestTime = k, v.lastAccess
+		}
+	}
+	if oldestKey != "" {
+		delete(c.orders, oldestKey)
+	}
+}
+
+var benchSizes = []int{100_000, 500_000}
+
+// benchImplementations возвращает текущую реализацию (lru) и прежнюю (scan) емкостью size
+func benchImplementations(size int) []struct {
+	name     string
+	newCache func() benchCache
+} {
+	return []struct {
+		name     string
+		newCache func() benchCache
+	}{
+		{"lru", func() benchCache { return newInMemoryCache(Options{MaxSize: size, TTL: time.Hour}.withDefaults()) }},
+		{"scan", func() benchCache { return newScanCache(size) }},
+	}
+}
+
+func testOrder(orderUID string) *entities.Order {
+	return &entities.Order{
+		OrderUID:    orderUID,
+		TrackNumber: "TRACK-" + orderUID,
+		Delivery:    entities.Delivery{Name: "Test Testov", City: "Kiryat Mozkin"},
+		Payment:     entities.Payment{Transaction: "tx-" + orderUID, Amount: 1817},
+		Items:       []entities.Item{{ChrtID: 9934930, Name: "Mascaras", Price: 453}},
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+// fill заполняет кэш до емкости size ключами 0..size-1
+func fill(c benchCache, size int) {
+	for i := 0; i < size; i++ {
+		key := strconv.Itoa(i)
+		c.Set(key, testOrder(key))
+	}
+}
+
+// BenchmarkSet добавляет новые заказы в заполненный кэш, так что каждый Set вытесняет запись
+func BenchmarkSet(b *testing.B) {
+	for _, size := range benchSizes {
+		for _, impl := range benchImplementations(size) {
+			b.Run(impl.name+"/"+strconv.Itoa(size), func(b *testing.B) {
+				c := impl.newCache()
+				fill(c, size)
+				order := testOrder("new")
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Set(strconv.Itoa(size+i), order)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGet читает заказы заполненного кэша
+func BenchmarkGet(b *testing.B) {
+	for _, size := range benchSizes {
+		for _, impl := range benchImplementations(size) {
+			b.Run(impl.name+"/"+strconv.Itoa(size), func(b *testing.B) {
+				c := impl.newCache()
+				fill(c, size)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, ok := c.Get(strconv.Itoa(i * 7919 % size)); !ok {
+						b.Fatal("order not found")
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestCacheCopiesAreIndependent изменяет заказы, переданные в Set и полученные из Get
+// и GetAll, одновременно с другими операциями кэша. Запускать с -race: гонка данных
+// означала бы, что вызывающий код и кэш разделяют один заказ.
+func TestCacheCopiesAreIndependent(t *testing.T) {
+	caches := map[string]interface {
+		benchCache
+		GetAll() map[string]*entities.Order
+		Close() error
+	}{
+		"inmemory": newInMemoryCache(Options{MaxSize: 1000}.withDefaults()),
+		"sharded":  NewShardedCache(Options{MaxSize: 1000, Shards: 4}),
+	}
+	const (
+		keys       = 50
+		goroutines = 8
+		iterations = 500
+	)
+	mutate := func(order *entities.Order) {
+		order.Delivery.City = "mutated"
+		order.Items[0].Name = "mutated"
+		order.Items = append(order.Items, entities.Item{Name: "extra"})
+	}
+
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < iterations; i++ {
+						key := strconv.Itoa((g + i) % keys)
+						switch i % 3 {
+						case 0:
+							order := testOrder(key)
+							c.Set(key, order)
+							mutate(order)
+						case 1:
+							if order, ok := c.Get(key); ok {
+								mutate(order)
+							}
+						default:
+							for _, order := range c.GetAll() {
+								mutate(order)
+							}
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			for key, order := range c.GetAll() {
+				want := testOrder(key)
+				if order.Delivery.City != want.Delivery.City || len(order.Items) != 1 || order.Items[0].Name != want.Items[0].Name {
+					t.Fatalf("cached order %s was modified through a copy: %+v", key, order)
+				}
+			}
+		})
+	}
+}
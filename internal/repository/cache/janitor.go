@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor периодически вызывает функцию очистки до остановки через stop
+type janitor struct {
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func startJanitor(interval time.Duration, cleanup func()) *janitor {
+	j := &janitor{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	go func() {
+		defer close(j.doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stopCh:
+				return
+			case <-ticker.C:
+				cleanup()
+			}
+		}
+	}()
+	return j
+}
+
+// stop останавливает горутину и дожидается ее завершения; повторные вызовы безопасны
+func (j *janitor) stop() {
+	j.stopOnce.Do(func() { close(j.stopCh) })
+	<-j.doneCh
+}
@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service0/internal/domain/entities"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisKeyPrefix = "order-service:"
+	defaultRedisTimeout   = time.Second
+	// redisBatchSize — сколько команд отправляется в Redis одним конвейером или MGET
+	redisBatchSize = 500
+)
+
+// RedisOptions задает параметры кэша в Redis
+type RedisOptions struct {
+	Addr      string
+	Password  string
+	DB        int
+	KeyPrefix string
+	TTL       time.Duration
+	// Timeout ограничивает время одной операции с Redis
+	Timeout time.Duration
+}
+
+// redisCache хранит заказы в Redis в виде JSON с TTL, поэтому кэш общий для всех
+// реплик сервиса. Ошибки Redis не прерывают работу: Get считает их промахом, а Set
+// только логирует.
+//
+// Вторичные ключи хранятся отдельными строками с тем же TTL, значение — order_uid.
+// При совпадении вторичного ключа у нескольких заказов индекс указывает на записанный последним.
+type redisCache struct {
+	client      *redis.Client
+	prefix      string
+	indexPrefix string
+	ttl         time.Duration
+	timeout     time.Duration
+	counters    cacheCounters
+}
+
+// NewRedisCache подключается к Redis и проверяет соединение
+func NewRedisCache(opts RedisOptions) (*redisCache, error) {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaultRedisKeyPrefix
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 15 * time.Minute // дефолтное время жизни
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRedisTimeout
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "failed to ping redis")
+	}
+
+	return &redisCache{
+		client:      client,
+		prefix:      opts.KeyPrefix + "order:",
+		indexPrefix: opts.KeyPrefix,
+		ttl:         opts.TTL,
+		timeout:     opts.Timeout,
+	}, nil
+}
+
+func (c *redisCache) key(orderUID string) string {
+	return c.prefix + orderUID
+}
+
+var redisIndexNames = [indexCount]string{
+	indexTrackNumber: "track:",
+	indexTransaction: "transaction:",
+}
+
+func (c *redisCache) indexKey(index secondaryIndex, value string) string {
+	return c.indexPrefix + redisIndexNames[index] + value
+}
+
+// setCommands добавляет в конвейер запись заказа и его вторичных ключей
+func (c *redisCache) setCommands(ctx context.Context, pipe redis.Pipeliner, orderUID string, order *entities.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, c.key(orderUID), data, c.ttl)
+	for i := secondaryIndex(0); i < indexCount; i++ {
+		if value := i.value(order); value != "" {
+			pipe.Set(ctx, c.indexKey(i, value), orderUID, c.ttl)
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) opContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+func (c *redisCache) Set(orderUID string, order *entities.Order) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		return c.setCommands(ctx, pipe, orderUID, order)
+	})
+	if err != nil {
+		log.Printf("Redis cache: failed to set order %s: %v", orderUID, err)
+	}
+}
+
+func (c *redisCache) Get(orderUID string) (*entities.Order, bool) {
+	return c.count(c.load(orderUID))
+}
+
+// load читает заказ без учета в статистике
+func (c *redisCache) load(orderUID string) (*entities.Order, bool) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+	data, err := c.client.Get(ctx, c.key(orderUID)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Redis cache: failed to get order %s: %v", orderUID, err)
+		}
+		return nil, false
+	}
+
+	var order entities.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		log.Printf("Redis cache: failed to unmarshal order %s: %v", orderUID, err)
+		return nil, false
+	}
+	return &order, true
+}
+
+// count учитывает результат обращения в статистике
+func (c *redisCache) count(order *entities.Order, ok bool) (*entities.Order, bool) {
+	if !ok {
+		c.counters.misses.Add(1)
+		return nil, false
+	}
+	c.counters.hits.Add(1)
+	return order, true
+}
+
+// Delete удаляет заказ. Ключи вторичных индексов истекают сами: getIndexed
+// считает промахом ссылку на отсутствующий заказ.
+func (c *redisCache) Delete(orderUID string) {
+	ctx, cancel := c.opContext()
+	defer cancel()
+	if err := c.client.Del(ctx, c.key(orderUID)).Err(); err != nil {
+		log.Printf("Redis cache: failed to delete order %s: %v", orderUID, err)
+	}
+}
+
+func (c *redisCache) GetByTrackNumber(trackNumber string) (*entities.Order, bool) {
+	return c.getIndexed(indexTrackNumber, trackNumber)
+}
+
+func (c *redisCache) GetByTransaction(transaction string) (*entities.Order, bool) {
+	return c.getIndexed(indexTransaction, transaction)
+}
+
+// getIndexed находит order_uid по вторичному ключу и загружает заказ. Если заказ уже
+// вытеснен или его ключ изменился, обращение считается промахом.
+func (c *redisCache) getIndexed(index secondaryIndex, value string) (*entities.Order, bool) {
+	ctx, cancel := c.opContext()
+	orderUID, err := c.client.Get(ctx, c.indexKey(index, value)).Result()
+	cancel()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Redis cache: failed to get index %s%s: %v", redisIndexNames[index], value, err)
+		}
+		return c.count(nil, false)
+	}
+
+	order, ok := c.load(orderUID)
+	return c.count(order, ok && index.value(order) == value)
+}
+
+// GetAll перебирает ключи заказов через SCAN и загружает их пачками через MGET
+func (c *redisCache) GetAll() map[string]*entities.Order {
+	result := make(map[string]*entities.Order)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*c.timeout)
+	defer cancel()
+
+	err := c.scanKeys(ctx, func(keys []string) error {
+		values, err := c.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue
+			}
+			var order entities.Order
+			if err := json.Unmarshal([]byte(data), &order); err != nil {
+				log.Printf("Redis cache: failed to unmarshal %s: %v", keys[i], err)
+				continue
+			}
+			result[strings.TrimPrefix(keys[i], c.prefix)] = &order
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Redis cache: failed to load all orders: %v", err)
+	}
+	return result
+}
+
+// Restore записывает заказы конвейером. Остальные ключи не удаляются, так как
+// кэш в Redis разделяется с другими репликами.
+func (c *redisCache) Restore(orders map[string]*entities.Order) {
+	batch := make([]*entities.Order, 0, len(orders))
+	for _, order := range orders {
+		batch = append(batch, order)
+	}
+	c.SetMany(batch)
+}
+
+// SetMany записывает заказы конвейером по redisBatchSize команд
+func (c *redisCache) SetMany(orders []*entities.Order) {
+	for start := 0; start < len(orders); start += redisBatchSize {
+		end := start + redisBatchSize
+		if end > len(orders) {
+			end = len(orders)
+		}
+
+		ctx, cancel := c.opContext()
+		_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, order := range orders[start:end] {
+				if err := c.setCommands(ctx, pipe, order.OrderUID, order); err != nil {
+					log.Printf("Redis cache: failed to marshal order %s: %v", order.OrderUID, err)
+				}
+			}
+			return nil
+		})
+		cancel()
+		if err != nil {
+			log.Printf("Redis cache: failed to write %d orders: %v", end-start, err)
+		}
+	}
+}
+
+// Stats возвращает счетчики этого экземпляра сервиса и объем памяти Redis. Размер кэша
+// не подсчитывается: для этого пришлось бы перебирать все ключи через SCAN на каждый запрос.
+// Емкость не ограничивается кэшем и определяется настройками maxmemory самого Redis.
+func (c *redisCache) Stats() entities.CacheStats {
+	ctx, cancel := c.opContext()
+	defer cancel()
+	return c.counters.snapshot(0, 0, c.usedMemory(ctx))
+}
+
+// usedMemory возвращает used_memory из INFO memory или 0, если получить его не удалось
+func (c *redisCache) usedMemory(ctx context.Context) int64 {
+	info, err := c.client.Info(ctx, "memory").Result()
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "used_memory:"); ok {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+func (c *redisCache) scanKeys(ctx context.Context, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, c.prefix+"*", redisBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"order-service0/internal/domain/entities"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const testRedisTTL = time.Minute
+
+func newTestRedisCache(t *testing.T) (*redisCache, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	c, err := NewRedisCache(RedisOptions{Addr: server.Addr(), TTL: testRedisTTL})
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func TestRedisCacheSetGetDelete(t *testing.T) {
+	c, _ := newTestRedisCache(t)
+
+	if _, ok := c.Get("1"); ok {
+		t.Fatal("Get on empty cache returned an order")
+	}
+	c.Set("1", testOrder("1"))
+	order, ok := c.Get("1")
+	if !ok || order.OrderUID != "1" || order.Delivery.City != "Kiryat Mozkin" || len(order.Items) != 1 {
+		t.Fatalf("Get returned %+v, %v", order, ok)
+	}
+
+	c.Delete("1")
+	if _, ok := c.Get("1"); ok {
+		t.Fatal("Get returned a deleted order")
+	}
+	if _, ok := c.GetByTrackNumber("TRACK-1"); ok {
+		t.Fatal("index lookup returned a deleted order")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 {
+		t.Fatalf("stats hits=%d misses=%d, want 1 and 3", stats.Hits, stats.Misses)
+	}
+}
+
+func TestRedisCacheSecondaryIndex(t *testing.T) {
+	c, _ := newTestRedisCache(t)
+	c.Set("1", testOrder("1"))
+
+	if order, ok := c.GetByTrackNumber("TRACK-1"); !ok || order.OrderUID != "1" {
+		t.Fatalf("GetByTrackNumber returned %+v, %v", order, ok)
+	}
+	if order, ok := c.GetByTransaction("tx-1"); !ok || order.OrderUID != "1" {
+		t.Fatalf("GetByTransaction returned %+v, %v", order, ok)
+	}
+
+	// Индекс старого трек-номера продолжает ссылаться на заказ, но не должен его находить
+	changed := testOrder("1")
+	changed.TrackNumber = "TRACK-NEW"
+	c.Set("1", changed)
+	if _, ok := c.GetByTrackNumber("TRACK-1"); ok {
+		t.Fatal("lookup by the previous track number returned the order")
+	}
+	if order, ok := c.GetByTrackNumber("TRACK-NEW"); !ok || order.OrderUID != "1" {
+		t.Fatalf("GetByTrackNumber after change returned %+v, %v", order, ok)
+	}
+}
+
+func TestRedisCacheTTL(t *testing.T) {
+	c, server := newTestRedisCache(t)
+	c.Set("1", testOrder("1"))
+
+	for _, key := range []string{c.key("1"), c.indexKey(indexTrackNumber, "TRACK-1"), c.indexKey(indexTransaction, "tx-1")} {
+		if ttl := server.TTL(key); ttl != testRedisTTL {
+			t.Fatalf("TTL of %s is %v, want %v", key, ttl, testRedisTTL)
+		}
+	}
+
+	server.FastForward(testRedisTTL + time.Second)
+	if _, ok := c.Get("1"); ok {
+		t.Fatal("Get returned an expired order")
+	}
+	if _, ok := c.GetByTransaction("tx-1"); ok {
+		t.Fatal("index lookup returned an expired order")
+	}
+}
+
+func TestRedisCacheSetMany(t *testing.T) {
+	c, server := newTestRedisCache(t)
+
+	// Больше redisBatchSize, чтобы запись шла несколькими конвейерами
+	const n = 2*redisBatchSize + 17
+	orders := make([]*entities.Order, n)
+	for i := range orders {
+		orders[i] = testOrder(strconv.Itoa(i))
+	}
+	c.SetMany(orders)
+
+	all := c.GetAll()
+	if len(all) != n {
+		t.Fatalf("GetAll returned %d orders, want %d", len(all), n)
+	}
+	for i := 0; i < n; i += 97 {
+		uid := strconv.Itoa(i)
+		if all[uid] == nil || all[uid].OrderUID != uid {
+			t.Fatalf("order %s is missing from GetAll", uid)
+		}
+		if ttl := server.TTL(c.key(uid)); ttl != testRedisTTL {
+			t.Fatalf("TTL of order %s is %v, want %v", uid, ttl, testRedisTTL)
+		}
+		if order, ok := c.GetByTransaction("tx-" + uid); !ok || order.OrderUID != uid {
+			t.Fatalf("GetByTransaction(tx-%s) returned %+v, %v", uid, order, ok)
+		}
+	}
+}
+
+func newTestTieredCache(t *testing.T) (*tieredCache, *inMemoryCache, *redisCache) {
+	t.Helper()
+	remote, _ := newTestRedisCache(t)
+	local := newInMemoryCache(Options{MaxSize: 100}.withDefaults())
+	return NewTieredCache(local, remote), local, remote
+}
+
+func TestTieredCacheReadThrough(t *testing.T) {
+	c, local, remote := newTestTieredCache(t)
+
+	// Заказ записан другой репликой и есть только в удаленном уровне
+	remote.Set("1", testOrder("1"))
+	if _, ok := local.Get("1"); ok {
+		t.Fatal("order is unexpectedly in the local tier")
+	}
+	if order, ok := c.Get("1"); !ok || order.OrderUID != "1" {
+		t.Fatalf("Get returned %+v, %v", order, ok)
+	}
+	if _, ok := local.Get("1"); !ok {
+		t.Fatal("Get did not populate the local tier")
+	}
+
+	remote.Set("2", testOrder("2"))
+	if order, ok := c.GetByTrackNumber("TRACK-2"); !ok || order.OrderUID != "2" {
+		t.Fatalf("GetByTrackNumber returned %+v, %v", order, ok)
+	}
+	if order, ok := local.GetByTrackNumber("TRACK-2"); !ok || order.OrderUID != "2" {
+		t.Fatal("index lookup did not populate the local tier")
+	}
+}
+
+func TestTieredCacheSetDelete(t *testing.T) {
+	c, local, remote := newTestTieredCache(t)
+
+	c.SetMany([]*entities.Order{testOrder("1"), testOrder("2")})
+	for _, tier := range []interface {
+		Get(string) (*entities.Order, bool)
+	}{local, remote} {
+		if _, ok := tier.Get("1"); !ok {
+			t.Fatal("SetMany did not write both tiers")
+		}
+	}
+
+	c.Delete("1")
+	if _, ok := local.Get("1"); ok {
+		t.Fatal("Delete left the order in the local tier")
+	}
+	if _, ok := remote.Get("1"); ok {
+		t.Fatal("Delete left the order in the remote tier")
+	}
+	if _, ok := c.Get("1"); ok {
+		t.Fatal("Get returned a deleted order")
+	}
+	if _, ok := c.Get("2"); !ok {
+		t.Fatal("Delete removed another order")
+	}
+}
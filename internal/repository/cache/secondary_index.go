@@ -0,0 +1,32 @@
+package cache
+
+import "order-service0/internal/domain/entities"
+
+// secondaryIndex — вторичный ключ, по которому заказ можно найти в кэше помимо order_uid
+type secondaryIndex int
+
+const (
+	indexTrackNumber secondaryIndex = iota
+	indexTransaction
+	indexCount
+)
+
+// value возвращает значение вторичного ключа заказа
+func (i secondaryIndex) value(order *entities.Order) string {
+	switch i {
+	case indexTrackNumber:
+		return order.TrackNumber
+	case indexTransaction:
+		return order.Payment.Transaction
+	}
+	return ""
+}
+
+// newerOrder сообщает, что a создан позже b. Если несколько заказов имеют одинаковый
+// вторичный ключ, кэш, как и база, возвращает самый новый из них.
+func newerOrder(a, b *entities.Order) bool {
+	if !a.DateCreated.Equal(b.DateCreated) {
+		return a.DateCreated.After(b.DateCreated)
+	}
+	return a.OrderUID > b.OrderUID
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"hash/fnv"
+
+	"order-service0/internal/domain/entities"
+)
+
+// shardedCache делит заказы между независимыми сегментами inMemoryCache по хэшу order_uid,
+// так что операции над разными сегментами не конкурируют за одну блокировку
+type shardedCache struct {
+	shards  []*inMemoryCache
+	janitor *janitor
+}
+
+// NewShardedCache создает кэш из opts.Shards сегментов. Емкость opts.MaxSize делится
+// между сегментами поровну, поэтому вытеснение выполняется внутри сегмента.
+func NewShardedCache(opts Options) *shardedCache {
+	opts = opts.withDefaults()
+	shardOpts := opts
+	shardOpts.MaxSize = (opts.MaxSize + opts.Shards - 1) / opts.Shards
+
+	cache := &shardedCache{shards: make([]*inMemoryCache, opts.Shards)}
+	for i := range cache.shards {
+		cache.shards[i] = newInMemoryCache(shardOpts)
+	}
+	// Запускаем горутину для периодической очистки всех сегментов, она останавливается в Close
+	cache.janitor = startJanitor(opts.CleanupInterval, cache.cleanupExpired)
+	return cache
+}
+
+func (c *shardedCache) shardIndex(orderUID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(orderUID))
+	return int(h.Sum32() % uint32(len(c.shards)))
+}
+
+func (c *shardedCache) shard(orderUID string) *inMemoryCache {
+	return c.shards[c.shardIndex(orderUID)]
+}
+
+func (c *shardedCache) Set(orderUID string, order *entities.Order) {
+	c.shard(orderUID).Set(orderUID, order)
+}
+
+func (c *shardedCache) SetMany(orders []*entities.Order) {
+	parts := make([][]*entities.Order, len(c.shards))
+	for _, order := range orders {
+		i := c.shardIndex(order.OrderUID)
+		parts[i] = append(parts[i], order)
+	}
+	for i, shard := range c.shards {
+		if len(parts[i]) > 0 {
+			shard.SetMany(parts[i])
+		}
+	}
+}
+
+func (c *shardedCache) Get(orderUID string) (*entities.Order, bool) {
+	return c.shard(orderUID).Get(orderUID)
+}
+
+func (c *shardedCache) Delete(orderUID string) {
+	c.shard(orderUID).Delete(orderUID)
+}
+
+func (c *shardedCache) GetByTrackNumber(trackNumber string) (*entities.Order, bool) {
+	return c.getIndexed(indexTrackNumber, trackNumber)
+}
+
+func (c *shardedCache) GetByTransaction(transaction string) (*entities.Order, bool) {
+	return c.getIndexed(indexTransaction, transaction)
+}
+
+// getIndexed опрашивает все сегменты, так как сегмент выбирается по order_uid, и
+// возвращает самый новый из найденных заказов. Обращение учитывается в статистике один раз.
+func (c *shardedCache) getIndexed(index secondaryIndex, value string) (*entities.Order, bool) {
+	var (
+		found      *entities.Order
+		foundEntry *cacheEntry
+		foundShard *inMemoryCache
+	)
+	for _, shard := range c.shards {
+		order, entry := shard.findIndexed(index, value)
+		if entry != nil && (found == nil || newerOrder(order, found)) {
+			found, foundEntry, foundShard = order, entry, shard
+		}
+	}
+	if found == nil {
+		c.shard(value).counters.misses.Add(1)
+		return nil, false
+	}
+	foundShard.counters.hits.Add(1)
+	foundShard.promoteFound(foundEntry)
+	return found, true
+}
+
+func (c *shardedCache) GetAll() map[string]*entities.Order {
+	result := make(map[string]*entities.Order)
+	for _, shard := range c.shards {
+		for k, v := range shard.GetAll() {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func (c *shardedCache) Restore(orders map[string]*entities.Order) {
+	parts := make([]map[string]*entities.Order, len(c.shards))
+	for i := range parts {
+		parts[i] = make(map[string]*entities.Order)
+	}
+	for k, v := range orders {
+		parts[c.shardIndex(k)][k] = v
+	}
+	for i, shard := range c.shards {
+		shard.Restore(parts[i])
+	}
+}
+
+func (c *shardedCache) cleanupExpired() {
+	for _, shard := range c.shards {
+		shard.cleanupExpired()
+	}
+}
+
+// Stats суммирует статистику всех сегментов
+func (c *shardedCache) Stats() entities.CacheStats {
+	var stats entities.CacheStats
+	for _, shard := range c.shards {
+		stats.Add(shard.Stats())
+	}
+	return stats
+}
+
+// Close останавливает фоновую очистку кэша
+func (c *shardedCache) Close() error {
+	c.janitor.stop()
+	return nil
+}
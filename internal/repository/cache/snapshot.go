@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"order-service0/internal/domain/entities"
+	"order-service0/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// Формат файла снимка:
+//
+//	magic [4]byte | version uint16 | createdAt int64 (unix nano) | length uint64 | crc32 uint32 | payload
+//
+// payload — gob-кодированный snapshotPayload длиной length, crc32 (IEEE) считается по payload.
+// Все числа записываются в big-endian.
+const (
+	snapshotMagic   = "OSCS"
+	snapshotVersion = 1
+)
+
+var (
+	// ErrSnapshotCorrupt — файл снимка поврежден или имеет неизвестный формат
+	ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+	// ErrSnapshotStale — снимок старше допустимого возраста
+	ErrSnapshotStale = errors.New("cache snapshot is too old")
+)
+
+type snapshotHeader struct {
+	Magic     [4]byte
+	Version   uint16
+	CreatedAt int64
+	Length    uint64
+	Checksum  uint32
+}
+
+type snapshotPayload struct {
+	Orders []*entities.Order
+}
+
+// SaveSnapshot атомарно записывает заказы в файл path через временный файл и rename
+func SaveSnapshot(path string, orders map[string]*entities.Order) error {
+	payload := snapshotPayload{Orders: make([]*entities.Order, 0, len(orders))}
+	for _, order := range orders {
+		payload.Orders = append(payload.Orders, order)
+	}
+
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(payload); err != nil {
+		return errors.Wrap(err, "failed to encode cache snapshot")
+	}
+
+	header := snapshotHeader{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UnixNano(),
+		Length:    uint64(body.Len()),
+		Checksum:  crc32.ChecksumIEEE(body.Bytes()),
+	}
+	copy(header.Magic[:], snapshotMagic)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create snapshot directory")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := binary.Write(tmp, binary.BigEndian, header); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write snapshot header")
+	}
+	if _, err := tmp.Write(body.Bytes()); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write snapshot body")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to sync snapshot file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to close snapshot file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "failed to replace snapshot file")
+}
+
+// LoadSnapshot читает снимок из path. Возвращает ErrSnapshotCorrupt, если файл поврежден,
+// и ErrSnapshotStale, если снимок старше maxAge (при maxAge > 0). Отсутствие файла
+// возвращается как ошибка, для которой errors.Is(err, os.ErrNotExist).
+func LoadSnapshot(path string, maxAge time.Duration) (map[string]*entities.Order, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+
+	var header snapshotHeader
+	if err := binary.Read(file, binary.BigEndian, &header); err != nil {
+		return nil, time.Time{}, errors.Wrapf(ErrSnapshotCorrupt, "read header: %v", err)
+	}
+	if string(header.Magic[:]) != snapshotMagic {
+		return nil, time.Time{}, errors.Wrap(ErrSnapshotCorrupt, "unexpected magic")
+	}
+	if header.Version != snapshotVersion {
+		return nil, time.Time{}, errors.Wrapf(ErrSnapshotCorrupt, "unsupported version %d", header.Version)
+	}
+
+	createdAt := time.Unix(0, header.CreatedAt)
+	if maxAge > 0 && time.Since(createdAt) > maxAge {
+		return nil, createdAt, errors.Wrapf(ErrSnapshotStale, "created at %s", createdAt.Format(time.RFC3339))
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, createdAt, errors.Wrap(err, "failed to stat snapshot file")
+	}
+	if header.Length > uint64(info.Size()) {
+		return nil, createdAt, errors.Wrap(ErrSnapshotCorrupt, "truncated body")
+	}
+	body := make([]byte, header.Length)
+	if _, err := io.ReadFull(file, body); err != nil {
+		return nil, createdAt, errors.Wrapf(ErrSnapshotCorrupt, "read body: %v", err)
+	}
+	if crc32.ChecksumIEEE(body) != header.Checksum {
+		return nil, createdAt, errors.Wrap(ErrSnapshotCorrupt, "checksum mismatch")
+	}
+
+	var payload snapshotPayload
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&payload); err != nil {
+		return nil, createdAt, errors.Wrapf(ErrSnapshotCorrupt, "decode body: %v", err)
+	}
+
+	orders := make(map[string]*entities.Order, len(payload.Orders))
+	for _, order := range payload.Orders {
+		if order != nil {
+			orders[order.OrderUID] = order
+		}
+	}
+	return orders, createdAt, nil
+}
+
+// Snapshotter периодически сохраняет содержимое кэша в файл
+type Snapshotter struct {
+	cache   repository.Cache
+	path    string
+	janitor *janitor
+}
+
+// StartSnapshotter запускает сохранение снимка кэша в path каждые interval
+func StartSnapshotter(cache repository.Cache, path string, interval time.Duration) *Snapshotter {
+	s := &Snapshotter{cache: cache, path: path}
+	s.janitor = startJanitor(interval, func() {
+		if err := s.Save(); err != nil {
+			log.Printf("Failed to save cache snapshot: %v", err)
+		}
+	})
+	return s
+}
+
+// Save сохраняет текущее содержимое кэша
+func (s *Snapshotter) Save() error {
+	return SaveSnapshot(s.path, s.cache.GetAll())
+}
+
+// Stop останавливает периодическое сохранение и записывает финальный снимок
+func (s *Snapshotter) Stop() error {
+	s.janitor.stop()
+	return s.Save()
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"order-service0/internal/domain/entities"
+)
+
+// cacheCounters — счетчики кэша, обновляемые без блокировок
+type cacheCounters struct {
+	hits              atomic.Uint64
+	misses            atomic.Uint64
+	expirations       atomic.Uint64
+	capacityEvictions atomic.Uint64
+	restoreEvictions  atomic.Uint64
+}
+
+func (c *cacheCounters) snapshot(size, capacity int, memory int64) entities.CacheStats {
+	return entities.NewCacheStats(
+		c.hits.Load(),
+		c.misses.Load(),
+		map[string]uint64{
+			entities.EvictionReasonCapacity: c.capacityEvictions.Load(),
+			entities.EvictionReasonRestore:  c.restoreEvictions.Load(),
+		},
+		c.expirations.Load(),
+		size, capacity, memory,
+	)
+}
+
+// Приблизительные накладные расходы на запись: элементы двух списков, ключ map и cacheEntry
+var entryOverhead = int64(unsafe.Sizeof(cacheEntry{})) + 2*64 + 48
+
+// estimateOrderSize грубо оценивает объем памяти, занимаемый заказом
+func estimateOrderSize(order *entities.Order) int64 {
+	if order == nil {
+		return 0
+	}
+	size := int64(unsafe.Sizeof(*order)) +
+		int64(len(order.OrderUID)+len(order.TrackNumber)+len(order.Entry)+len(order.Locale)+
+			len(order.InternalSignature)+len(order.CustomerID)+len(order.DeliveryService)+
+			len(order.ShardKey)+len(order.OOFShard)) +
+		int64(len(order.Delivery.Name)+len(order.Delivery.Phone)+len(order.Delivery.Zip)+
+			len(order.Delivery.City)+len(order.Delivery.Address)+len(order.Delivery.Region)+
+			len(order.Delivery.Email)) +
+		int64(len(order.Payment.Transaction)+len(order.Payment.RequestID)+len(order.Payment.Currency)+
+			len(order.Payment.Provider)+len(order.Payment.Bank))
+	for _, item := range order.Items {
+		size += int64(unsafe.Sizeof(item)) +
+			int64(len(item.TrackNumber)+len(item.RID)+len(item.Name)+len(item.Size)+len(item.Brand))
+	}
+	return size
+}
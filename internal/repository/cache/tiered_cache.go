@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"order-service0/internal/domain/entities"
+	"order-service0/internal/repository"
+)
+
+// tieredCache — двухуровневый кэш: быстрый локальный уровень перед общим удаленным.
+// Запись идет в оба уровня, промах локального уровня дочитывается из удаленного
+// и сохраняется локально.
+type tieredCache struct {
+	local  repository.Cache
+	remote repository.Cache
+}
+
+func NewTieredCache(local, remote repository.Cache) *tieredCache {
+	return &tieredCache{local: local, remote: remote}
+}
+
+func (c *tieredCache) Set(orderUID string, order *entities.Order) {
+	c.local.Set(orderUID, order)
+	c.remote.Set(orderUID, order)
+}
+
+func (c *tieredCache) SetMany(orders []*entities.Order) {
+	c.local.SetMany(orders)
+	c.remote.SetMany(orders)
+}
+
+func (c *tieredCache) Get(orderUID string) (*entities.Order, bool) {
+	if order, ok := c.local.Get(orderUID); ok {
+		return order, true
+	}
+	order, ok := c.remote.Get(orderUID)
+	if ok {
+		c.local.Set(orderUID, order)
+	}
+	return order, ok
+}
+
+func (c *tieredCache) Delete(orderUID string) {
+	c.remote.Delete(orderUID)
+	c.local.Delete(orderUID)
+}
+
+func (c *tieredCache) GetByTrackNumber(trackNumber string) (*entities.Order, bool) {
+	return c.getIndexed(trackNumber, c.local.GetByTrackNumber, c.remote.GetByTrackNumber)
+}
+
+func (c *tieredCache) GetByTransaction(transaction string) (*entities.Order, bool) {
+	return c.getIndexed(transaction, c.local.GetByTransaction, c.remote.GetByTransaction)
+}
+
+func (c *tieredCache) getIndexed(value string, local, remote func(string) (*entities.Order, bool)) (*entities.Order, bool) {
+	if order, ok := local(value); ok {
+		return order, true
+	}
+	order, ok := remote(value)
+	if ok {
+		c.local.Set(order.OrderUID, order)
+	}
+	return order, ok
+}
+
+// GetAll возвращает содержимое удаленного уровня как более полного
+func (c *tieredCache) GetAll() map[string]*entities.Order {
+	return c.remote.GetAll()
+}
+
+func (c *tieredCache) Restore(orders map[string]*entities.Order) {
+	c.local.Restore(orders)
+	c.remote.Restore(orders)
+}
+
+// Stats возвращает статистику локального уровня, где попаданиями считаются и
+// дочитывания из удаленного уровня, а промахами — только промахи обоих уровней
+func (c *tieredCache) Stats() entities.CacheStats {
+	local := c.local.Stats()
+	remote := c.remote.Stats()
+	return entities.NewCacheStats(
+		local.Hits+remote.Hits,
+		remote.Misses,
+		local.Evictions,
+		local.Expirations,
+		local.Size,
+		local.Capacity,
+		local.MemoryEstimateBytes,
+	)
+}
+
+func (c *tieredCache) Close() error {
+	localErr := c.local.Close()
+	if err := c.remote.Close(); err != nil {
+		return err
+	}
+	return localErr
+}
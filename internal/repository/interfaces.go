@@ -2,26 +2,63 @@ package repository
 import (
 	"context"
 	"order-service0/internal/domain/entities"
+	"time"
 )
 
 // OrderRepository определяет контракт для работы с хранилищем заказов
 type OrderRepository interface {
 	// Create сохраняет заказ в базу данных
 	Create(ctx context.Context, order *entities.Order) error
+	// CreateBatch сохраняет несколько заказов одной транзакцией
+	CreateBatch(ctx context.Context, orders []*entities.Order) error
+	// Insert сохраняет только новый заказ; повтор с тем же ключом идемпотентности возвращает replayed
+	Insert(ctx context.Context, order *entities.Order, idempotencyKey string) (replayed bool, err error)
+	// UpdateStatus атомарно меняет статус заказа и записывает переход в историю
+	UpdateStatus(ctx context.Context, change entities.OrderStatusChange) error
+	// ApplyUpdate применяет частичное изменение заказа, отклоняя устаревшие версии
+	ApplyUpdate(ctx context.Context, update entities.OrderUpdate) error
 	// GetByUID возвращает заказ по его уникальному идентификатору
 	GetByUID(ctx context.Context, orderUID string) (*entities.Order, error)
+	// GetByTrackNumber возвращает самый новый заказ с трек-номером trackNumber
+	GetByTrackNumber(ctx context.Context, trackNumber string) (*entities.Order, error)
+	// GetByTransaction возвращает самый новый заказ с транзакцией оплаты transaction
+	GetByTransaction(ctx context.Context, transaction string) (*entities.Order, error)
 	// GetAll возвращает все заказы из базы данных
 	GetAll(ctx context.Context) ([]*entities.Order, error)
+	// StreamRecent передает заказы от новых к старым страницами по pageSize, не более limit штук
+	StreamRecent(ctx context.Context, limit, pageSize int, fn func(orders []*entities.Order) error) error
+	// Search возвращает страницу заказов, подходящих под фильтр
+	Search(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error)
+	// GetHistory возвращает версии заказа по возрастанию номера
+	GetHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error)
+	// GetVersion возвращает версию number заказа с принятыми данными и снимком
+	GetVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error)
+	// Delete помечает заказ удаленным; удаленные заказы не возвращаются при чтении
+	Delete(ctx context.Context, orderUID string, deletedAt time.Time) error
+	// EraseCustomer стирает персональные данные покупателя и сохраняет запись аудита
+	EraseCustomer(ctx context.Context, erasure *entities.CustomerErasure) error
 }
 
 // Cache определяет контракт для кэширования заказов в памяти
 type Cache interface {
 	// Set сохраняет заказ в кэше
 	Set(orderUID string, order *entities.Order)
+	// SetMany сохраняет несколько заказов за одну операцию
+	SetMany(orders []*entities.Order)
 	// Get возвращает заказ из кэша по orderUID
 	Get(orderUID string) (*entities.Order, bool)
+	// Delete удаляет заказ из кэша
+	Delete(orderUID string)
+	// GetByTrackNumber возвращает самый новый заказ с трек-номером trackNumber
+	GetByTrackNumber(trackNumber string) (*entities.Order, bool)
+	// GetByTransaction возвращает самый новый заказ с транзакцией оплаты transaction
+	GetByTransaction(transaction string) (*entities.Order, bool)
 	// GetAll возвращает все заказы из кэша
 	GetAll() map[string]*entities.Order
 	// Restore восстанавливает кэш из переданной мапы заказов
 	Restore(orders map[string]*entities.Order)
+	// Stats возвращает снимок счетчиков кэша
+	Stats() entities.CacheStats
+	// Close останавливает фоновые процессы кэша
+	Close() error
 }
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"order-service0/internal/domain/apperrors"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// mapError приводит ошибки драйвера к ошибкам предметной области: потеря соединения,
+// таймауты и конфликты сериализации становятся apperrors.ErrUnavailable,
+// нарушение уникальности — apperrors.ErrConflict
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, apperrors.ErrOrderNotFound) ||
+		errors.Is(err, apperrors.ErrConflict) ||
+		errors.Is(err, apperrors.ErrUnavailable) {
+		return err
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		switch {
+		case code == "23505": // unique_violation
+			return apperrors.Conflict(err)
+		case code == "40001", code == "40P01", // serialization_failure, deadlock_detected
+			strings.HasPrefix(code, "08"),  // connection_exception
+			strings.HasPrefix(code, "53"),  // insufficient_resources
+			strings.HasPrefix(code, "57P"): // admin_shutdown, crash_shutdown, cannot_connect_now
+			return apperrors.Unavailable(err)
+		}
+		return err
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) {
+		return apperrors.Unavailable(err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return apperrors.Unavailable(err)
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"order-service0/internal/domain/entities"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxQueryParams — ограничение PostgreSQL на число параметров в одном запросе
+const maxQueryParams = 65535
+
+const (
+	orderColumns    = `order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status, status_changed_at, version`
+	deliveryColumns = `order_uid, name, phone, zip, city, address, region, email`
+	paymentColumns  = `order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee`
+	itemColumns     = `order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status`
+)
+
+// CreateBatch сохраняет заказы одной транзакцией, используя многострочные INSERT.
+// Заказы, order_uid которых уже есть в базе, обрабатываются так же, как в Create.
+// Если в пачке несколько заказов с одним order_uid, сохраняется последний. Устаревший
+// по версии заказ отменяет всю пачку с ErrStaleVersion, и сообщения пачки обрабатываются по одному.
+func (r *orderRepository) CreateBatch(ctx context.Context, orders []*entities.Order) error {
+	return mapError(r.createBatch(ctx, orders))
+}
+
+func (r *orderRepository) createBatch(ctx context.Context, orders []*entities.Order) error {
+	orders = dedupeOrders(orders)
+	if len(orders) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	rows := make([][]interface{}, 0, len(orders))
+	for _, order := range orders {
+		rows = append(rows, orderArgs(order))
+	}
+	inserted, err := r.insertOrders(ctx, tx, rows)
+	if err != nil {
+		return err
+	}
+
+	var fresh []*entities.Order
+	for _, order := range orders {
+		if inserted[order.OrderUID] {
+			fresh = append(fresh, order)
+			continue
+		}
+		if err := r.resolveConflict(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+
+	if err := r.insertDetailsBatch(ctx, tx, fresh); err != nil {
+		return err
+	}
+	if err := r.recordFirstVersions(ctx, tx, fresh); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertOrders вставляет строки заказов, пропуская уже существующие,
+// и возвращает множество действительно вставленных order_uid
+func (r *orderRepository) insertOrders(ctx context.Context, tx *sql.Tx, rows [][]interface{}) (map[string]bool, error) {
+	inserted := make(map[string]bool, len(rows))
+	err := forEachChunk(rows, func(chunk [][]interface{}) error {
+		values, args := buildValues(chunk)
+		query := `INSERT INTO orders (` + orderColumns + `) VALUES ` + values +
+			` ON CONFLICT (order_uid) DO NOTHING RETURNING order_uid`
+		res, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert orders")
+		}
+		defer res.Close()
+
+		for res.Next() {
+			var orderUID string
+			if err := res.Scan(&orderUID); err != nil {
+				return errors.Wrap(err, "failed to scan inserted order UID")
+			}
+			inserted[orderUID] = true
+		}
+		return errors.Wrap(res.Err(), "failed to read inserted order UIDs")
+	})
+	return inserted, err
+}
+
+// insertDetailsBatch сохраняет доставки, оплаты и товары новых заказов
+func (r *orderRepository) insertDetailsBatch(ctx context.Context, tx *sql.Tx, orders []*entities.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	deliveries := make([][]interface{}, 0, len(orders))
+	payments := make([][]interface{}, 0, len(orders))
+	var items [][]interface{}
+	for _, order := range orders {
+		deliveries = append(deliveries, deliveryArgs(order))
+		payments = append(payments, paymentArgs(order))
+		for _, item := range order.Items {
+			items = append(items, itemArgs(order.OrderUID, item))
+		}
+	}
+
+	tables := []struct {
+		name    string
+		columns string
+		rows    [][]interface{}
+	}{
+		{"deliveries", deliveryColumns, deliveries},
+		{"payments", paymentColumns, payments},
+		{"items", itemColumns, items},
+	}
+	for _, t := range tables {
+		err := forEachChunk(t.rows, func(chunk [][]interface{}) error {
+			values, args := buildValues(chunk)
+			_, err := tx.ExecContext(ctx, `INSERT INTO `+t.name+` (`+t.columns+`) VALUES `+values, args...)
+			return errors.Wrapf(err, "failed to insert %s", t.name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupeOrders оставляет для каждого order_uid последний заказ, сохраняя порядок первых вхождений
+func dedupeOrders(orders []*entities.Order) []*entities.Order {
+	index := make(map[string]int, len(orders))
+	result := make([]*entities.Order, 0, len(orders))
+	for _, order := range orders {
+		if i, ok := index[order.OrderUID]; ok {
+			result[i] = order
+			continue
+		}
+		index[order.OrderUID] = len(result)
+		result = append(result, order)
+	}
+	return result
+}
+
+// forEachChunk делит строки на части, укладывающиеся в лимит параметров запроса
+func forEachChunk(rows [][]interface{}, fn func(chunk [][]interface{}) error) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	size := maxQueryParams / len(rows[0])
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := fn(rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildValues строит список VALUES вида ($1, $2), ($3, $4) и плоский список аргументов
+func buildValues(rows [][]interface{}) (string, []interface{}) {
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(rows)*len(rows[0]))
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			args = append(args, v)
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(len(args)))
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String(), args
+}
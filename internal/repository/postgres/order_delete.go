@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"order-service0/internal/domain/entities"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Delete помечает заказ удаленным. Строки заказа остаются в базе, но больше не
+// возвращаются при чтении, а повторная доставка заказа отклоняется с ErrConflict.
+func (r *orderRepository) Delete(ctx context.Context, orderUID string, deletedAt time.Time) error {
+	return mapError(r.delete(ctx, orderUID, deletedAt))
+}
+
+func (r *orderRepository) delete(ctx context.Context, orderUID string, deletedAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	order, err := r.getByUID(ctx, tx, orderUID, true)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET deleted_at = $2 WHERE order_uid = $1`, orderUID, deletedAt); err != nil {
+		return errors.Wrap(err, "failed to delete order")
+	}
+	err = r.recordVersion(ctx, tx, versionRecord{
+		orderUID: orderUID,
+		change:   entities.ChangeDeleted,
+		payload:  map[string]time.Time{"deleted_at": deletedAt},
+		before:   order,
+		after:    order,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EraseCustomer стирает персональные данные получателя во всех заказах покупателя,
+// включая удаленные, и в их истории версий, после чего сохраняет запись аудита.
+// ID и OrderUIDs записи заполняются по результату.
+func (r *orderRepository) EraseCustomer(ctx context.Context, erasure *entities.CustomerErasure) error {
+	return mapError(r.eraseCustomer(ctx, erasure))
+}
+
+func (r *orderRepository) eraseCustomer(ctx context.Context, erasure *entities.CustomerErasure) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	orderUIDs, err := lockCustomerOrders(ctx, tx, erasure.CustomerID)
+	if err != nil {
+		return err
+	}
+	if len(orderUIDs) > 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE orders SET erased_at = $2 WHERE order_uid = ANY($1)`,
+			pq.Array(orderUIDs), erasure.RequestedAt)
+		if err != nil {
+			return errors.Wrap(err, "failed to mark orders erased")
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE deliveries SET name = $2, phone = $2, address = $2, email = $2
+		                  WHERE order_uid = ANY($1)`, pq.Array(orderUIDs), entities.ErasedValue)
+		if err != nil {
+			return errors.Wrap(err, "failed to erase deliveries")
+		}
+		if err := eraseVersions(ctx, tx, orderUIDs); err != nil {
+			return err
+		}
+	}
+
+	source, err := json.Marshal(erasure.Source)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode erasure source")
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO customer_erasures
+	                  (customer_id, requested_by, reason, source, requested_at, order_uids)
+	                  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		erasure.CustomerID, erasure.RequestedBy, erasure.Reason, string(source), erasure.RequestedAt,
+		pq.Array(orderUIDs)).Scan(&erasure.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to save erasure audit record")
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	erasure.OrderUIDs = orderUIDs
+	return nil
+}
+
+// lockCustomerOrders блокирует все заказы покупателя и возвращает их order_uid
+func lockCustomerOrders(ctx context.Context, tx *sql.Tx, customerID string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT order_uid FROM orders WHERE customer_id = $1
+	                  ORDER BY order_uid FOR UPDATE`, customerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lock customer orders")
+	}
+	defer rows.Close()
+
+	orderUIDs := []string{}
+	for rows.Next() {
+		var orderUID string
+		if err := rows.Scan(&orderUID); err != nil {
+			return nil, errors.Wrap(err, "failed to scan order uid")
+		}
+		orderUIDs = append(orderUIDs, orderUID)
+	}
+	return orderUIDs, errors.Wrap(rows.Err(), "failed to read customer orders")
+}
+
+// erasedVersion — JSON-колонки версии после стирания персональных данных
+type erasedVersion struct {
+	id                      int64
+	payload, snapshot, diff string
+}
+
+// eraseVersions стирает персональные данные получателя в принятых данных, снимках
+// и отличиях всех версий заказов orderUIDs
+func eraseVersions(ctx context.Context, tx *sql.Tx, orderUIDs []string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, payload, snapshot, diff FROM order_versions
+	                  WHERE order_uid = ANY($1) ORDER BY id`, pq.Array(orderUIDs))
+	if err != nil {
+		return errors.Wrap(err, "failed to query order versions")
+	}
+
+	var erased []erasedVersion
+	for rows.Next() {
+		var (
+			version                 erasedVersion
+			payload, snapshot, diff []byte
+		)
+		if err := rows.Scan(&version.id, &payload, &snapshot, &diff); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "failed to scan order version")
+		}
+		if version.payload, err = eraseDocument(payload); err == nil {
+			if version.snapshot, err = eraseDocument(snapshot); err == nil {
+				version.diff, err = eraseDiff(diff)
+			}
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		erased = append(erased, version)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to read order versions")
+	}
+
+	// Обновления выполняются после чтения: соединение транзакции занято, пока открыт rows
+	for _, version := range erased {
+		_, err := tx.ExecContext(ctx, `UPDATE order_versions SET payload = $2, snapshot = $3, diff = $4 WHERE id = $1`,
+			version.id, version.payload, version.snapshot, version.diff)
+		if err != nil {
+			return errors.Wrap(err, "failed to erase order version")
+		}
+	}
+	return nil
+}
+
+// eraseDocument стирает персональные данные в поле delivery JSON-документа заказа или изменения
+func eraseDocument(data []byte) (string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", errors.Wrap(err, "failed to decode order version")
+	}
+	if fields, ok := doc.(map[string]interface{}); ok {
+		eraseDelivery(fields["delivery"])
+	}
+	return encodeErased(doc)
+}
+
+// eraseDiff стирает старые и новые значения персональных полей в отличиях версии
+func eraseDiff(data []byte) (string, error) {
+	var diff []entities.FieldChange
+	if err := json.Unmarshal(data, &diff); err != nil {
+		return "", errors.Wrap(err, "failed to decode order version diff")
+	}
+	for i := range diff {
+		change := &diff[i]
+		if change.Path == "delivery" {
+			eraseDelivery(change.Old)
+			eraseDelivery(change.New)
+			continue
+		}
+		for _, field := range entities.ErasedDeliveryFields {
+			if change.Path == "delivery."+field {
+				change.Old, change.New = erasedValue(change.Old), erasedValue(change.New)
+			}
+		}
+	}
+	return encodeErased(diff)
+}
+
+// eraseDelivery заменяет персональные поля JSON-объекта доставки
+func eraseDelivery(delivery interface{}) {
+	fields, ok := delivery.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, field := range entities.ErasedDeliveryFields {
+		if _, ok := fields[field]; ok {
+			fields[field] = erasedValue(fields[field])
+		}
+	}
+}
+
+// erasedValue стирает значение поля, сохраняя отсутствие значения
+func erasedValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return entities.ErasedValue
+}
+
+func encodeErased(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode order version")
+	}
+	return string(data), nil
+}
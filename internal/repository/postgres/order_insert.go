@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+
+	"github.com/pkg/errors"
+)
+
+// Insert сохраняет новый заказ независимо от ConflictPolicy: если заказ с таким order_uid
+// уже есть, возвращается ErrConflict. Непустой idempotencyKey сохраняется вместе с заказом.
+// Повтор с тем же ключом и тем же заказом ничего не меняет и возвращает replayed = true,
+// а ключ, уже использованный для другого заказа, — ErrIdempotencyKeyReused.
+func (r *orderRepository) Insert(ctx context.Context, order *entities.Order, idempotencyKey string) (bool, error) {
+	replayed, err := r.insert(ctx, order, idempotencyKey)
+	return replayed, mapError(err)
+}
+
+func (r *orderRepository) insert(ctx context.Context, order *entities.Order, idempotencyKey string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	orderQuery := `INSERT INTO orders (` + orderColumns + `) 
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	                  ON CONFLICT (order_uid) DO NOTHING`
+	res, err := tx.ExecContext(ctx, orderQuery, orderArgs(order)...)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert order")
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get affected rows")
+	}
+	if inserted == 0 {
+		return r.checkReplay(ctx, tx, order, idempotencyKey)
+	}
+
+	if err := r.insertDetails(ctx, tx, order); err != nil {
+		return false, err
+	}
+	if idempotencyKey != "" {
+		if err := r.saveIdempotencyKey(ctx, tx, idempotencyKey, order.OrderUID); err != nil {
+			return false, err
+		}
+	}
+	err = r.recordVersion(ctx, tx, versionRecord{
+		orderUID: order.OrderUID,
+		change:   entities.ChangeCreated,
+		payload:  order,
+		after:    order,
+	})
+	if err != nil {
+		return false, err
+	}
+	return false, tx.Commit()
+}
+
+// checkReplay определяет, является ли вставка уже существующего заказа повтором запроса
+// с тем же ключом идемпотентности
+func (r *orderRepository) checkReplay(ctx context.Context, tx *sql.Tx, order *entities.Order, idempotencyKey string) (bool, error) {
+	conflict := errors.Wrapf(apperrors.ErrConflict, "order %s already exists", order.OrderUID)
+	if idempotencyKey == "" {
+		return false, conflict
+	}
+
+	var orderUID string
+	err := tx.QueryRowContext(ctx, `SELECT order_uid FROM idempotency_keys WHERE key = $1`, idempotencyKey).Scan(&orderUID)
+	if err == sql.ErrNoRows {
+		return false, conflict
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get idempotency key")
+	}
+	if orderUID != order.OrderUID {
+		return false, errors.Wrapf(apperrors.ErrIdempotencyKeyReused, "key was used for order %s", orderUID)
+	}
+
+	existing, err := r.getByUID(ctx, tx, order.OrderUID, false)
+	if errors.Is(err, apperrors.ErrOrderNotFound) {
+		return false, errors.Wrapf(apperrors.ErrConflict, "order %s was deleted", order.OrderUID)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to load existing order")
+	}
+	keepServiceFields(order, existing)
+	if !sameOrder(existing, order) {
+		return false, errors.Wrapf(apperrors.ErrIdempotencyKeyReused, "order %s has different payload", order.OrderUID)
+	}
+	return true, nil
+}
+
+// saveIdempotencyKey связывает ключ с новым заказом. Одновременный запрос с тем же
+// ключом ждет фиксации этой транзакции на уникальном индексе.
+func (r *orderRepository) saveIdempotencyKey(ctx context.Context, tx *sql.Tx, idempotencyKey, orderUID string) error {
+	res, err := tx.ExecContext(ctx, `INSERT INTO idempotency_keys (key, order_uid) VALUES ($1, $2)
+	                  ON CONFLICT (key) DO NOTHING`, idempotencyKey, orderUID)
+	if err != nil {
+		return errors.Wrap(err, "failed to save idempotency key")
+	}
+	saved, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+	if saved == 0 {
+		return errors.Wrap(apperrors.ErrIdempotencyKeyReused, "key was used for another order")
+	}
+	return nil
+}
@@ -3,42 +3,166 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"order-service0/internal/domain/apperrors"
 	"order-service0/internal/domain/entities"
+	"reflect"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// ConflictPolicy определяет поведение Create, если заказ с таким order_uid уже сохранен
+// и его содержимое отличается от нового
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyUpsert атомарно заменяет сохраненный заказ новым
+	ConflictPolicyUpsert ConflictPolicy = "upsert"
+	// ConflictPolicyReject отклоняет новый заказ с ошибкой apperrors.ErrConflict
+	ConflictPolicyReject ConflictPolicy = "reject"
+)
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type orderRepository struct {
-	db *sql.DB
+	db             *sql.DB
+	conflictPolicy ConflictPolicy
 }
 
-func NewOrderRepository(db *sql.DB) *orderRepository {
-	return &orderRepository{db: db}
+func NewOrderRepository(db *sql.DB, conflictPolicy ConflictPolicy) *orderRepository {
+	if conflictPolicy == "" {
+		conflictPolicy = ConflictPolicyUpsert
+	}
+	return &orderRepository{db: db, conflictPolicy: conflictPolicy}
 }
 
+// Create сохраняет заказ. Повторная доставка того же заказа ничего не меняет,
+// а заказ с тем же order_uid, но другим содержимым, обрабатывается согласно ConflictPolicy.
 func (r *orderRepository) Create(ctx context.Context, order *entities.Order) error {
+	return mapError(r.create(ctx, order))
+}
+
+func (r *orderRepository) create(ctx context.Context, order *entities.Order) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
 	defer tx.Rollback()
 
-	orderQuery := `INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, 
-	                  customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) 
-	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = tx.ExecContext(ctx, orderQuery,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard)
+	if err := r.store(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// store сохраняет заказ в рамках транзакции tx
+func (r *orderRepository) store(ctx context.Context, tx *sql.Tx, order *entities.Order) error {
+	orderQuery := `INSERT INTO orders (` + orderColumns + `) 
+	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	                  ON CONFLICT (order_uid) DO NOTHING`
+	res, err := tx.ExecContext(ctx, orderQuery, orderArgs(order)...)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert order")
 	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+
+	if inserted == 0 {
+		return r.resolveConflict(ctx, tx, order)
+	}
+	if err := r.insertDetails(ctx, tx, order); err != nil {
+		return err
+	}
+	return r.recordVersion(ctx, tx, versionRecord{
+		orderUID: order.OrderUID,
+		change:   entities.ChangeCreated,
+		payload:  order,
+		after:    order,
+	})
+}
 
+// resolveConflict обрабатывает заказ, order_uid которого уже есть в базе. Если к заказу уже
+// применялись версионированные события, отличающийся заказ не новее сохраненного отклоняется
+// с ErrStaleVersion, чтобы повторная доставка order.created не затерла более поздние изменения.
+func (r *orderRepository) resolveConflict(ctx context.Context, tx *sql.Tx, order *entities.Order) error {
+	existing, err := r.getByUID(ctx, tx, order.OrderUID, true)
+	if errors.Is(err, apperrors.ErrOrderNotFound) {
+		// Удаленный заказ не восстанавливается повторной доставкой
+		return errors.Wrapf(apperrors.ErrConflict, "order %s was deleted", order.OrderUID)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to load existing order")
+	}
+	incomingVersion := order.Version
+	// Статус и версия сохраненного заказа не меняются при повторной доставке или перезаписи
+	keepServiceFields(order, existing)
+	if sameOrder(existing, order) {
+		// Повторная доставка того же заказа
+		return nil
+	}
+	if existing.Version > 0 && incomingVersion <= existing.Version {
+		return errors.Wrapf(apperrors.ErrStaleVersion, "order %s: event version %d, stored version %d",
+			order.OrderUID, incomingVersion, existing.Version)
+	}
+	if incomingVersion > existing.Version {
+		order.Version = incomingVersion
+	}
+	if r.conflictPolicy != ConflictPolicyUpsert {
+		return errors.Wrapf(apperrors.ErrConflict, "order %s already exists with different payload", order.OrderUID)
+	}
+	// Перезапись стертого заказа вернула бы удаленные персональные данные
+	var erased bool
+	err = tx.QueryRowContext(ctx, `SELECT erased_at IS NOT NULL FROM orders WHERE order_uid = $1`, order.OrderUID).Scan(&erased)
+	if err != nil {
+		return errors.Wrap(err, "failed to check order erasure")
+	}
+	if erased {
+		return errors.Wrapf(apperrors.ErrConflict, "personal data of order %s was erased", order.OrderUID)
+	}
+	if err := r.replaceOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	return r.recordVersion(ctx, tx, versionRecord{
+		orderUID: order.OrderUID,
+		change:   entities.ChangeReplaced,
+		payload:  order,
+		before:   existing,
+		after:    order,
+	})
+}
+
+// replaceOrder перезаписывает заказ и все связанные с ним записи
+func (r *orderRepository) replaceOrder(ctx context.Context, tx *sql.Tx, order *entities.Order) error {
+	orderQuery := `UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+	                  customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10,
+	                  oof_shard = $11, status = $12, status_changed_at = $13, version = $14
+	                  WHERE order_uid = $1`
+	_, err := tx.ExecContext(ctx, orderQuery, orderArgs(order)...)
+	if err != nil {
+		return errors.Wrap(err, "failed to update order")
+	}
+
+	for _, table := range []string{"deliveries", "payments", "items"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_uid = $1`, order.OrderUID); err != nil {
+			return errors.Wrapf(err, "failed to delete %s", table)
+		}
+	}
+
+	return r.insertDetails(ctx, tx, order)
+}
+
+// insertDetails сохраняет доставку, оплату и товары заказа
+func (r *orderRepository) insertDetails(ctx context.Context, tx *sql.Tx, order *entities.Order) error {
 	deliveryQuery := `INSERT INTO deliveries (order_uid, name, phone, zip, city, address, region, email) 
 	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err = tx.ExecContext(ctx, deliveryQuery,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
-		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email)
+	_, err := tx.ExecContext(ctx, deliveryQuery, deliveryArgs(order)...)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert delivery")
 	}
@@ -46,10 +170,7 @@ func (r *orderRepository) Create(ctx context.Context, order *entities.Order) err
 	paymentQuery := `INSERT INTO payments (order_uid, transaction, request_id, currency, provider, 
 	                  amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) 
 	                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = tx.ExecContext(ctx, paymentQuery,
-		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
-		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
-		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee)
+	_, err = tx.ExecContext(ctx, paymentQuery, paymentArgs(order)...)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert payment")
 	}
@@ -58,63 +179,119 @@ func (r *orderRepository) Create(ctx context.Context, order *entities.Order) err
 	                total_price, nm_id, brand, status) 
 	                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx, itemQuery,
-			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
-			item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status)
+		_, err = tx.ExecContext(ctx, itemQuery, itemArgs(order.OrderUID, item)...)
 		if err != nil {
 			return errors.Wrap(err, "failed to insert item")
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (r *orderRepository) GetByUID(ctx context.Context, orderUID string) (*entities.Order, error) {
-	query := `
+	order, err := r.getByUID(ctx, r.db, orderUID, false)
+	return order, mapError(err)
+}
+
+// orderSelectQuery выбирает не удаленные заказы вместе с доставкой и оплатой; порядок колонок
+// соответствует scanOrder. Дополнительные условия добавляются через AND.
+const orderSelectQuery = `
 		SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
 		       o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+		       o.status, o.status_changed_at, o.version,
 		       d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 		       p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, 
 		       p.bank, p.delivery_cost, p.goods_total, p.custom_fee
 		FROM orders o
 		LEFT JOIN deliveries d ON o.order_uid = d.order_uid
 		LEFT JOIN payments p ON o.order_uid = p.order_uid
-		WHERE o.order_uid = $1`
+		WHERE o.deleted_at IS NULL`
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanOrder читает строку orderSelectQuery без товаров
+func scanOrder(row rowScanner) (*entities.Order, error) {
 	var order entities.Order
 	var delivery entities.Delivery
 	var payment entities.Payment
 
-	err := r.db.QueryRowContext(ctx, query, orderUID).Scan(
+	err := row.Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
 		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+		&order.Status, &order.StatusChangedAt, &order.Version,
 		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
 		&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount,
 		&payment.PaymentDT, &payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("order not found")
-		}
-		return nil, errors.Wrap(err, "failed to get order")
+		return nil, err
 	}
 
 	order.Delivery = delivery
 	order.Payment = payment
+	return &order, nil
+}
 
-	items, err := r.getItemsByOrderUID(ctx, orderUID)
+// getByUID загружает заказ через q. При forUpdate строка заказа блокируется до конца транзакции.
+func (r *orderRepository) getByUID(ctx context.Context, q queryer, orderUID string, forUpdate bool) (*entities.Order, error) {
+	query := orderSelectQuery + `
+		AND o.order_uid = $1`
+	if forUpdate {
+		query += ` FOR UPDATE OF o`
+	}
+
+	order, err := scanOrder(q.QueryRowContext(ctx, query, orderUID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.Wrapf(apperrors.ErrOrderNotFound, "order %s", orderUID)
+		}
+		return nil, errors.Wrap(err, "failed to get order")
+	}
+
+	items, err := r.getItemsByOrderUID(ctx, q, orderUID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get items")
 	}
 	order.Items = items
 
-	return &order, nil
+	return order, nil
 }
 
-func (r *orderRepository) getItemsByOrderUID(ctx context.Context, orderUID string) ([]entities.Item, error) {
+// GetByTrackNumber возвращает самый новый заказ с трек-номером trackNumber
+func (r *orderRepository) GetByTrackNumber(ctx context.Context, trackNumber string) (*entities.Order, error) {
+	order, err := r.getLatest(ctx, "o.track_number", "track number", trackNumber)
+	return order, mapError(err)
+}
+
+// GetByTransaction возвращает самый новый заказ с транзакцией оплаты transaction
+func (r *orderRepository) GetByTransaction(ctx context.Context, transaction string) (*entities.Order, error) {
+	order, err := r.getLatest(ctx, "p.transaction", "transaction", transaction)
+	return order, mapError(err)
+}
+
+// getLatest загружает самый новый заказ, у которого column равна value; name используется в ошибке
+func (r *orderRepository) getLatest(ctx context.Context, column, name, value string) (*entities.Order, error) {
+	query := orderSelectQuery + `
+		AND ` + column + ` = $1
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT 1`
+	orders, err := r.queryOrders(ctx, query, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, errors.Wrapf(apperrors.ErrOrderNotFound, "order with %s %s", name, value)
+	}
+	return orders[0], nil
+}
+
+func (r *orderRepository) getItemsByOrderUID(ctx context.Context, q queryer, orderUID string) ([]entities.Item, error) {
 	query := `SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status 
-	          FROM items WHERE order_uid = $1`
-	rows, err := r.db.QueryContext(ctx, query, orderUID)
+	          FROM items WHERE order_uid = $1 ORDER BY id`
+	rows, err := q.QueryContext(ctx, query, orderUID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query items")
 	}
@@ -136,25 +313,71 @@ func (r *orderRepository) getItemsByOrderUID(ctx context.Context, orderUID strin
 }
 
 func (r *orderRepository) GetAll(ctx context.Context) ([]*entities.Order, error) {
-	query := `SELECT order_uid FROM orders`
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get order UIDs")
-	}
-	defer rows.Close()
+	orders, err := r.getAll(ctx)
+	return orders, mapError(err)
+}
 
+func (r *orderRepository) getAll(ctx context.Context) ([]*entities.Order, error) {
 	var orders []*entities.Order
-	for rows.Next() {
-		var orderUID string
-		if err := rows.Scan(&orderUID); err != nil {
-			return nil, errors.Wrap(err, "failed to scan order UID")
-		}
-		order, err := r.GetByUID(ctx, orderUID)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get order %s", orderUID)
-		}
-		orders = append(orders, order)
+	err := r.streamRecent(ctx, 0, defaultPageSize, func(page []*entities.Order) error {
+		orders = append(orders, page...)
+		return nil
+	})
+	return orders, err
+}
+
+func orderArgs(order *entities.Order) []interface{} {
+	return []interface{}{
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OOFShard,
+		order.Status, order.StatusChangedAt, order.Version,
 	}
+}
+
+func deliveryArgs(order *entities.Order) []interface{} {
+	return []interface{}{
+		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+		order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+	}
+}
+
+func paymentArgs(order *entities.Order) []interface{} {
+	return []interface{}{
+		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency,
+		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
+		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+	}
+}
+
+func itemArgs(orderUID string, item entities.Item) []interface{} {
+	return []interface{}{
+		orderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
+		item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status,
+	}
+}
+
+// sameOrder сравнивает сохраненный заказ с пришедшим. Время создания сравнивается
+// с точностью до микросекунд без учета часового пояса, так как колонка date_created
+// имеет тип TIMESTAMP.
+func sameOrder(stored, incoming *entities.Order) bool {
+	a, b := *stored, *incoming
+	// Статус и версия управляются сервисом и не входят в содержимое заказа
+	keepServiceFields(&b, &a)
+	a.DateCreated = wallClock(a.DateCreated)
+	b.DateCreated = wallClock(b.DateCreated)
+	if len(a.Items) == 0 && len(b.Items) == 0 {
+		a.Items, b.Items = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// keepServiceFields переносит в order статус и версию из сохраненного заказа existing
+func keepServiceFields(order, existing *entities.Order) {
+	order.Status, order.StatusChangedAt = existing.Status, existing.StatusChangedAt
+	order.Version = existing.Version
+}
 
-	return orders, nil
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond()/int(time.Microsecond)*int(time.Microsecond), time.UTC)
 }
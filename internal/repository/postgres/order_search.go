@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"order-service0/internal/domain/entities"
+	"strconv"
+	"strings"
+)
+
+// Search возвращает страницу заказов, подходящих под filter, отсортированных по
+// (date_created, order_uid) в направлении filter.Sort. Страницы выбираются по ключу
+// из filter.After, как в StreamRecent.
+func (r *orderRepository) Search(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error) {
+	page, err := r.search(ctx, filter)
+	return page, mapError(err)
+}
+
+func (r *orderRepository) search(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	where, args := searchConditions(filter)
+	direction, compare := "DESC", "<"
+	if filter.Sort == entities.SortAsc {
+		direction, compare = "ASC", ">"
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.DateCreated, filter.After.OrderUID)
+		where = append(where, "(o.date_created, o.order_uid) "+compare+
+			" ($"+strconv.Itoa(len(args)-1)+", $"+strconv.Itoa(len(args))+")")
+	}
+
+	query := orderSelectQuery
+	if len(where) > 0 {
+		query += `
+		AND ` + strings.Join(where, " AND ")
+	}
+	// Запрашиваем на одну строку больше, чтобы узнать, есть ли следующая страница
+	args = append(args, limit+1)
+	query += `
+		ORDER BY o.date_created ` + direction + `, o.order_uid ` + direction + `
+		LIMIT $` + strconv.Itoa(len(args))
+
+	orders, err := r.queryOrders(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entities.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.Next = &entities.OrderCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID}
+	}
+	return page, nil
+}
+
+// searchConditions строит условия WHERE и их аргументы для непустых полей фильтра
+func searchConditions(filter entities.OrderFilter) ([]string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	// param добавляет аргумент и возвращает его плейсхолдер
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	equals := []struct {
+		column string
+		value  string
+	}{
+		{"o.customer_id", filter.CustomerID},
+		{"o.track_number", filter.TrackNumber},
+		{"o.delivery_service", filter.DeliveryService},
+		{"o.locale", filter.Locale},
+		{"p.provider", filter.Provider},
+		{"p.bank", filter.Bank},
+		{"p.currency", filter.Currency},
+	}
+	for _, eq := range equals {
+		if eq.value != "" {
+			where = append(where, eq.column+" = "+param(eq.value))
+		}
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "o.date_created >= "+param(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "o.date_created <= "+param(filter.CreatedTo))
+	}
+
+	// Бренд и артикул проверяются у одного товара
+	var itemConditions []string
+	if filter.Brand != "" {
+		itemConditions = append(itemConditions, "i.brand = "+param(filter.Brand))
+	}
+	if filter.NMID != 0 {
+		itemConditions = append(itemConditions, "i.nm_id = "+param(filter.NMID))
+	}
+	if len(itemConditions) > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND "+
+			strings.Join(itemConditions, " AND ")+")")
+	}
+	return where, args
+}
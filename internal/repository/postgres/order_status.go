@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+
+	"github.com/pkg/errors"
+)
+
+// UpdateStatus переводит заказ из change.From в change.To и записывает переход в историю.
+// Если статус заказа уже отличается от change.From, возвращается ErrConflict.
+func (r *orderRepository) UpdateStatus(ctx context.Context, change entities.OrderStatusChange) error {
+	return mapError(r.updateStatus(ctx, change))
+}
+
+func (r *orderRepository) updateStatus(ctx context.Context, change entities.OrderStatusChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	before, err := r.getByUID(ctx, tx, change.OrderUID, true)
+	if err != nil {
+		return err
+	}
+	if err := r.changeStatus(ctx, tx, change); err != nil {
+		return err
+	}
+	after, err := r.getByUID(ctx, tx, change.OrderUID, false)
+	if err != nil {
+		return err
+	}
+	err = r.recordVersion(ctx, tx, versionRecord{
+		orderUID: change.OrderUID,
+		change:   entities.ChangeStatus,
+		payload:  change,
+		before:   before,
+		after:    after,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// changeStatus выполняет переход статуса в рамках транзакции tx
+func (r *orderRepository) changeStatus(ctx context.Context, tx *sql.Tx, change entities.OrderStatusChange) error {
+	res, err := tx.ExecContext(ctx, `UPDATE orders SET status = $3, status_changed_at = $4
+	                  WHERE order_uid = $1 AND status = $2`,
+		change.OrderUID, change.From, change.To, change.ChangedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to update order status")
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get affected rows")
+	}
+	if updated == 0 {
+		return errors.Wrapf(apperrors.ErrConflict, "order %s is no longer in status %s", change.OrderUID, change.From)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_status_history (order_uid, from_status, to_status, reason, changed_at)
+	                  VALUES ($1, $2, $3, $4, $5)`,
+		change.OrderUID, change.From, change.To, change.Reason, change.ChangedAt)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert status history")
+	}
+	return nil
+}
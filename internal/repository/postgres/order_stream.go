@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"order-service0/internal/domain/entities"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const defaultPageSize = 500
+
+// StreamRecent передает в fn заказы от новых к старым (по date_created) страницами
+// по pageSize, пока не будет выдано limit заказов. При limit <= 0 выдаются все заказы.
+// Страницы выбираются по ключу (date_created, order_uid), без OFFSET, а товары
+// загружаются одним запросом на страницу.
+func (r *orderRepository) StreamRecent(ctx context.Context, limit, pageSize int, fn func(orders []*entities.Order) error) error {
+	return mapError(r.streamRecent(ctx, limit, pageSize, fn))
+}
+
+func (r *orderRepository) streamRecent(ctx context.Context, limit, pageSize int, fn func(orders []*entities.Order) error) error {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var (
+		lastCreated time.Time
+		lastUID     string
+		loaded      int
+	)
+	for limit <= 0 || loaded < limit {
+		size := pageSize
+		if limit > 0 && limit-loaded < size {
+			size = limit - loaded
+		}
+
+		page, err := r.loadPage(ctx, lastCreated, lastUID, loaded == 0, size)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		loaded += len(page)
+		last := page[len(page)-1]
+		lastCreated, lastUID = last.DateCreated, last.OrderUID
+		if len(page) < size {
+			return nil
+		}
+	}
+	return nil
+}
+
+// loadPage загружает страницу заказов, созданных раньше (afterCreated, afterUID)
+func (r *orderRepository) loadPage(ctx context.Context, afterCreated time.Time, afterUID string, first bool, size int) ([]*entities.Order, error) {
+	query := orderSelectQuery
+	args := []interface{}{size}
+	if !first {
+		query += `
+		AND (o.date_created, o.order_uid) < ($2, $3)`
+		args = append(args, afterCreated, afterUID)
+	}
+	query += `
+		ORDER BY o.date_created DESC, o.order_uid DESC
+		LIMIT $1`
+
+	return r.queryOrders(ctx, query, args...)
+}
+
+// queryOrders выполняет запрос на основе orderSelectQuery и загружает товары найденных заказов
+func (r *orderRepository) queryOrders(ctx context.Context, query string, args ...interface{}) ([]*entities.Order, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query orders")
+	}
+	defer rows.Close()
+
+	var orders []*entities.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan order")
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read orders")
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	if err := r.attachItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// attachItems загружает товары сразу для всех переданных заказов
+func (r *orderRepository) attachItems(ctx context.Context, orders []*entities.Order) error {
+	byUID := make(map[string]*entities.Order, len(orders))
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		byUID[order.OrderUID] = order
+		uids = append(uids, order.OrderUID)
+	}
+
+	query := `SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status 
+	          FROM items WHERE order_uid = ANY($1) ORDER BY order_uid, id`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(uids))
+	if err != nil {
+		return errors.Wrap(err, "failed to query items")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderUID string
+		var item entities.Item
+		if err := rows.Scan(
+			&orderUID, &item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale,
+			&item.Size, &item.TotalPrice, &item.NMID, &item.Brand, &item.Status,
+		); err != nil {
+			return errors.Wrap(err, "failed to scan item")
+		}
+		if order, ok := byUID[orderUID]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+	return errors.Wrap(rows.Err(), "failed to read items")
+}
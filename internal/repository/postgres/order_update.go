@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ApplyUpdate применяет частичное изменение заказа одной транзакцией. Изменение с версией
+// не новее сохраненной отклоняется с ErrStaleVersion, иначе версия заказа становится равной
+// update.Version.
+func (r *orderRepository) ApplyUpdate(ctx context.Context, update entities.OrderUpdate) error {
+	return mapError(r.applyUpdate(ctx, update))
+}
+
+func (r *orderRepository) applyUpdate(ctx context.Context, update entities.OrderUpdate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	before, err := r.getByUID(ctx, tx, update.OrderUID, true)
+	if err != nil {
+		return err
+	}
+	if update.Version <= before.Version {
+		return errors.Wrapf(apperrors.ErrStaleVersion, "order %s: event version %d, stored version %d",
+			update.OrderUID, update.Version, before.Version)
+	}
+
+	if err := r.updateOrderRow(ctx, tx, update); err != nil {
+		return err
+	}
+
+	// deliveryArgs и paymentArgs принимают заказ целиком
+	order := &entities.Order{OrderUID: update.OrderUID}
+	if update.Delivery != nil {
+		order.Delivery = *update.Delivery
+		_, err := tx.ExecContext(ctx, `UPDATE deliveries SET name = $2, phone = $3, zip = $4, city = $5,
+		                  address = $6, region = $7, email = $8
+		                  WHERE order_uid = $1`, deliveryArgs(order)...)
+		if err != nil {
+			return errors.Wrap(err, "failed to update delivery")
+		}
+	}
+	if update.Payment != nil {
+		order.Payment = *update.Payment
+		_, err := tx.ExecContext(ctx, `UPDATE payments SET transaction = $2, request_id = $3, currency = $4,
+		                  provider = $5, amount = $6, payment_dt = $7, bank = $8, delivery_cost = $9,
+		                  goods_total = $10, custom_fee = $11
+		                  WHERE order_uid = $1`, paymentArgs(order)...)
+		if err != nil {
+			return errors.Wrap(err, "failed to update payment")
+		}
+	}
+	if update.Items != nil {
+		if err := r.replaceItems(ctx, tx, update.OrderUID, *update.Items); err != nil {
+			return err
+		}
+	}
+	if update.StatusChange != nil {
+		if err := r.changeStatus(ctx, tx, *update.StatusChange); err != nil {
+			return err
+		}
+	}
+
+	rec := versionRecord{orderUID: update.OrderUID, change: entities.ChangeUpdated, payload: update, before: before}
+	if update.StatusChange != nil {
+		// Отмена заказа меняет только статус
+		rec.change, rec.payload = entities.ChangeStatus, update.StatusChange
+	}
+	if rec.after, err = r.getByUID(ctx, tx, update.OrderUID, false); err != nil {
+		return err
+	}
+	if err := r.recordVersion(ctx, tx, rec); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateOrderRow обновляет версию и заданные поля строки заказа
+func (r *orderRepository) updateOrderRow(ctx context.Context, tx *sql.Tx, update entities.OrderUpdate) error {
+	set := []string{"version = $2"}
+	args := []interface{}{update.OrderUID, update.Version}
+	if update.TrackNumber != nil {
+		args = append(args, *update.TrackNumber)
+		set = append(set, "track_number = $"+strconv.Itoa(len(args)))
+	}
+	if update.DeliveryService != nil {
+		args = append(args, *update.DeliveryService)
+		set = append(set, "delivery_service = $"+strconv.Itoa(len(args)))
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE orders SET `+strings.Join(set, ", ")+` WHERE order_uid = $1`, args...)
+	return errors.Wrap(err, "failed to update order")
+}
+
+// replaceItems заменяет все товары заказа
+func (r *orderRepository) replaceItems(ctx context.Context, tx *sql.Tx, orderUID string, items []entities.Item) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM items WHERE order_uid = $1`, orderUID); err != nil {
+		return errors.Wrap(err, "failed to delete items")
+	}
+
+	rows := make([][]interface{}, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, itemArgs(orderUID, item))
+	}
+	return forEachChunk(rows, func(chunk [][]interface{}) error {
+		values, args := buildValues(chunk)
+		_, err := tx.ExecContext(ctx, `INSERT INTO items (`+itemColumns+`) VALUES `+values, args...)
+		return errors.Wrap(err, "failed to insert items")
+	})
+}
@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const versionColumns = `order_uid, change, source, received_at, payload, snapshot, diff`
+
+// versionRecord описывает изменение заказа для записи в order_versions.
+// before равен nil для нового заказа.
+type versionRecord struct {
+	orderUID string
+	change   string
+	payload  interface{}
+	before   *entities.Order
+	after    *entities.Order
+}
+
+// recordVersion добавляет версию заказа в рамках транзакции tx. Строка заказа к этому
+// моменту уже заблокирована транзакцией, поэтому номер версии вычисляется без гонок.
+func (r *orderRepository) recordVersion(ctx context.Context, tx *sql.Tx, rec versionRecord) error {
+	args, err := versionArgs(ctx, rec)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO order_versions (`+versionColumns+`, number)
+	                  SELECT $1, $2, $3, $4, $5, $6, $7, COALESCE(MAX(number), 0) + 1
+	                  FROM order_versions WHERE order_uid = $1`, args...)
+	return errors.Wrap(err, "failed to insert order version")
+}
+
+// recordFirstVersions добавляет первые версии только что вставленных заказов одним запросом
+func (r *orderRepository) recordFirstVersions(ctx context.Context, tx *sql.Tx, orders []*entities.Order) error {
+	rows := make([][]interface{}, 0, len(orders))
+	for _, order := range orders {
+		args, err := versionArgs(ctx, versionRecord{
+			orderUID: order.OrderUID,
+			change:   entities.ChangeCreated,
+			payload:  order,
+			after:    order,
+		})
+		if err != nil {
+			return err
+		}
+		rows = append(rows, append(args, 1))
+	}
+	return forEachChunk(rows, func(chunk [][]interface{}) error {
+		values, args := buildValues(chunk)
+		_, err := tx.ExecContext(ctx, `INSERT INTO order_versions (`+versionColumns+`, number) VALUES `+values, args...)
+		return errors.Wrap(err, "failed to insert order versions")
+	})
+}
+
+// versionArgs возвращает значения колонок versionColumns
+func versionArgs(ctx context.Context, rec versionRecord) ([]interface{}, error) {
+	var diff []entities.FieldChange
+	if rec.before != nil {
+		diff = diffOrders(rec.before, rec.after)
+	}
+
+	values := []interface{}{entities.ChangeSourceFor(ctx, rec.orderUID), rec.payload, rec.after, diff}
+	encoded := make([]interface{}, len(values))
+	for i, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode order version")
+		}
+		// Строка, а не []byte: lib/pq передает []byte как bytea, а колонки имеют тип JSONB
+		encoded[i] = string(data)
+	}
+
+	return []interface{}{
+		rec.orderUID, rec.change, encoded[0], time.Now().UTC(), encoded[1], encoded[2], encoded[3],
+	}, nil
+}
+
+// GetHistory возвращает все версии заказа по возрастанию номера без данных и снимков
+func (r *orderRepository) GetHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error) {
+	history, err := r.getHistory(ctx, orderUID)
+	return history, mapError(err)
+}
+
+func (r *orderRepository) getHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT number, change, source, received_at, diff
+	                  FROM order_versions WHERE order_uid = $1 ORDER BY number`, orderUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query order versions")
+	}
+	defer rows.Close()
+
+	var history []entities.OrderVersion
+	for rows.Next() {
+		version := entities.OrderVersion{OrderUID: orderUID}
+		var source, diff []byte
+		if err := rows.Scan(&version.Number, &version.Change, &source, &version.ReceivedAt, &diff); err != nil {
+			return nil, errors.Wrap(err, "failed to scan order version")
+		}
+		if err := decodeVersion(&version, source, diff, nil, nil); err != nil {
+			return nil, err
+		}
+		history = append(history, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read order versions")
+	}
+	if len(history) == 0 {
+		return nil, errors.Wrapf(apperrors.ErrOrderNotFound, "order %s", orderUID)
+	}
+	return history, nil
+}
+
+// GetVersion возвращает версию number заказа вместе с принятыми данными и снимком заказа
+func (r *orderRepository) GetVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error) {
+	version, err := r.getVersion(ctx, orderUID, number)
+	return version, mapError(err)
+}
+
+func (r *orderRepository) getVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error) {
+	version := &entities.OrderVersion{OrderUID: orderUID, Number: number}
+	var source, diff, payload, snapshot []byte
+	err := r.db.QueryRowContext(ctx, `SELECT change, source, received_at, diff, payload, snapshot
+	                  FROM order_versions WHERE order_uid = $1 AND number = $2`, orderUID, number).
+		Scan(&version.Change, &source, &version.ReceivedAt, &diff, &payload, &snapshot)
+	if err == sql.ErrNoRows {
+		return nil, errors.Wrapf(apperrors.ErrVersionNotFound, "version %d of order %s", number, orderUID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get order version")
+	}
+
+	version.Order = &entities.Order{}
+	if err := decodeVersion(version, source, diff, payload, snapshot); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// decodeVersion разбирает JSON-колонки версии; nil-колонки пропускаются
+func decodeVersion(version *entities.OrderVersion, source, diff, payload, snapshot []byte) error {
+	if err := json.Unmarshal(source, &version.Source); err != nil {
+		return errors.Wrap(err, "failed to decode version source")
+	}
+	if err := json.Unmarshal(diff, &version.Diff); err != nil {
+		return errors.Wrap(err, "failed to decode version diff")
+	}
+	if version.Diff == nil {
+		version.Diff = []entities.FieldChange{}
+	}
+	if payload != nil {
+		version.Payload = payload
+	}
+	if snapshot != nil {
+		if err := json.Unmarshal(snapshot, version.Order); err != nil {
+			return errors.Wrap(err, "failed to decode version snapshot")
+		}
+	}
+	return nil
+}
+
+// diffOrders сравнивает JSON-представления заказов и возвращает измененные поля.
+// Время приводится к точности колонок TIMESTAMP, как в sameOrder.
+func diffOrders(before, after *entities.Order) []entities.FieldChange {
+	var a, b interface{}
+	for _, pair := range []struct {
+		order *entities.Order
+		dst   *interface{}
+	}{{before, &a}, {after, &b}} {
+		order := *pair.order
+		order.DateCreated = wallClock(order.DateCreated)
+		order.StatusChangedAt = wallClock(order.StatusChangedAt)
+		if data, err := json.Marshal(order); err == nil {
+			json.Unmarshal(data, pair.dst)
+		}
+	}
+	var changes []entities.FieldChange
+	diffValues("", a, b, &changes)
+	return changes
+}
+
+func diffValues(path string, a, b interface{}, changes *[]entities.FieldChange) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			keys := make(map[string]struct{}, len(av)+len(bv))
+			for k := range av {
+				keys[k] = struct{}{}
+			}
+			for k := range bv {
+				keys[k] = struct{}{}
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				child := k
+				if path != "" {
+					child = path + "." + k
+				}
+				diffValues(child, av[k], bv[k], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			n := len(av)
+			if len(bv) > n {
+				n = len(bv)
+			}
+			for i := 0; i < n; i++ {
+				var x, y interface{}
+				if i < len(av) {
+					x = av[i]
+				}
+				if i < len(bv) {
+					y = bv[i]
+				}
+				diffValues(path+"["+strconv.Itoa(i)+"]", x, y, changes)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, entities.FieldChange{Path: path, Old: a, New: b})
+	}
+}
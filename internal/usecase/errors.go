@@ -0,0 +1,12 @@
+package usecase
+
+import (
+	"order-service0/internal/domain/apperrors"
+
+	"github.com/pkg/errors"
+)
+
+// IsTemporary сообщает, имеет ли смысл повторить операцию, завершившуюся ошибкой err
+func IsTemporary(err error) bool {
+	return errors.Is(err, apperrors.ErrUnavailable)
+}
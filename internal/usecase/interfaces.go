@@ -3,26 +3,81 @@ package usecase
 import (
 	"context"
 	"order-service0/internal/domain/entities"
+	"time"
 )
 
+// OrderMessage — сообщение с событием заказа и его источником для истории версий
+type OrderMessage struct {
+	Value  []byte
+	Source entities.ChangeSource
+}
+
 // OrderUseCase определяет бизнес-логику работы с заказами
 type OrderUseCase interface {
 	CreateOrder(ctx context.Context, order *entities.Order) error
+	// SubmitOrder сохраняет новый заказ, пришедший по HTTP; заказ с существующим order_uid
+	// отклоняется с ErrConflict. Повтор с тем же idempotencyKey возвращает replayed = true.
+	SubmitOrder(ctx context.Context, order *entities.Order, idempotencyKey string) (replayed bool, err error)
+	// ChangeOrderStatus переводит заказ в статус status, если переход разрешен
+	ChangeOrderStatus(ctx context.Context, orderUID string, status entities.OrderStatus, reason string) (*entities.Order, error)
 	GetOrderByUID(ctx context.Context, orderUID string) (*entities.Order, error)
+	// GetOrderByTrackNumber и GetOrderByTransaction возвращают самый новый заказ
+	// с указанным трек-номером или транзакцией оплаты
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*entities.Order, error)
+	GetOrderByTransaction(ctx context.Context, transaction string) (*entities.Order, error)
 	ProcessOrderMessage(ctx context.Context, message []byte) error
+	// ProcessOrderBatch сохраняет корректные заказы из пачки сообщений одной транзакцией.
+	// Ошибки разбора и валидации возвращаются в rejected по индексу сообщения,
+	// ошибка сохранения всей пачки — в err.
+	ProcessOrderBatch(ctx context.Context, messages []OrderMessage) (rejected map[int]error, err error)
+	// SearchOrders возвращает страницу заказов по фильтру, минуя кэш
+	SearchOrders(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error)
+	// GetOrderHistory и GetOrderVersion возвращают историю версий заказа из базы
+	GetOrderHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error)
+	GetOrderVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error)
+	// DeleteOrder помечает заказ удаленным и убирает его из кэша
+	DeleteOrder(ctx context.Context, orderUID string) error
+	// EraseCustomer стирает персональные данные получателя во всех заказах покупателя
+	// и возвращает запись аудита. DeleteOrder и EraseCustomer очищают только кэш своего
+	// экземпляра и общий Redis: локальный кэш других реплик (backend memory или tiered)
+	// отдает прежние данные до истечения cache.ttl.
+	EraseCustomer(ctx context.Context, customerID, requestedBy, reason string) (*entities.CustomerErasure, error)
 }
 
 // OrderRepository определяет контракт для работы с хранилищем заказов
 type OrderRepository interface {
 	Create(ctx context.Context, order *entities.Order) error
+	CreateBatch(ctx context.Context, orders []*entities.Order) error
+	Insert(ctx context.Context, order *entities.Order, idempotencyKey string) (replayed bool, err error)
+	UpdateStatus(ctx context.Context, change entities.OrderStatusChange) error
+	ApplyUpdate(ctx context.Context, update entities.OrderUpdate) error
 	GetByUID(ctx context.Context, orderUID string) (*entities.Order, error)
+	GetByTrackNumber(ctx context.Context, trackNumber string) (*entities.Order, error)
+	GetByTransaction(ctx context.Context, transaction string) (*entities.Order, error)
 	GetAll(ctx context.Context) ([]*entities.Order, error)
+	StreamRecent(ctx context.Context, limit, pageSize int, fn func(orders []*entities.Order) error) error
+	Search(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error)
+	GetHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error)
+	GetVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error)
+	Delete(ctx context.Context, orderUID string, deletedAt time.Time) error
+	EraseCustomer(ctx context.Context, erasure *entities.CustomerErasure) error
 }
 
 // Cache определяет контракт для кэширования
 type Cache interface {
 	Set(orderUID string, order *entities.Order)
+	SetMany(orders []*entities.Order)
 	Get(orderUID string) (*entities.Order, bool)
+	Delete(orderUID string)
+	GetByTrackNumber(trackNumber string) (*entities.Order, bool)
+	GetByTransaction(transaction string) (*entities.Order, bool)
 	GetAll() map[string]*entities.Order
 	Restore(orders map[string]*entities.Order)
+	Stats() entities.CacheStats
+	Close() error
+}
+
+// StatusEventPublisher публикует события смены статуса заказа
+type StatusEventPublisher interface {
+	PublishStatusChange(ctx context.Context, change entities.OrderStatusChange) error
 }
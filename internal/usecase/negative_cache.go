@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"sync"
+	"time"
+)
+
+// maxNegativeEntries ограничивает число запомненных отсутствующих заказов,
+// чтобы поток запросов с несуществующими ID не раздувал память
+const maxNegativeEntries = 10000
+
+// negativeCache запоминает order_uid, которых нет в базе, на короткое время ttl
+type negativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (c *negativeCache) contains(orderUID string) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt, ok := c.entries[orderUID]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(c.entries, orderUID)
+		return false
+	}
+	return true
+}
+
+func (c *negativeCache) add(orderUID string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxNegativeEntries {
+		for k, expiresAt := range c.entries {
+			if now.After(expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxNegativeEntries {
+			return
+		}
+	}
+	c.entries[orderUID] = now.Add(c.ttl)
+}
+
+func (c *negativeCache) remove(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, orderUID)
+}
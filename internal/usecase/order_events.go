@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"order-service0/internal/domain/apperrors"
+	"order-service0/internal/domain/entities"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Типы событий заказа в топике Kafka
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderUpdated   = "order.updated"
+	EventOrderCancelled = "order.cancelled"
+)
+
+// orderEvent — конверт сообщения о заказе. Version растет с каждым событием заказа и
+// нужна, чтобы не применять устаревшие изменения. Сообщение без event_type считается
+// заказом целиком, то есть событием order.created версии 0.
+type orderEvent struct {
+	EventType string          `json:"event_type"`
+	Version   int64           `json:"version"`
+	OrderUID  string          `json:"order_uid"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// cancellation — содержимое события order.cancelled
+type cancellation struct {
+	Reason string `json:"reason"`
+}
+
+func parseOrderEvent(message []byte) (*orderEvent, error) {
+	var event orderEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		return nil, errors.Wrap(apperrors.Validation(err), "failed to unmarshal order message")
+	}
+	if event.EventType == "" {
+		return &orderEvent{EventType: EventOrderCreated, Payload: message}, nil
+	}
+
+	var fields []apperrors.FieldError
+	switch event.EventType {
+	case EventOrderCreated, EventOrderUpdated, EventOrderCancelled:
+	default:
+		fields = append(fields, apperrors.FieldError{Field: "event_type", Message: "unknown event type " + strconv.Quote(event.EventType)})
+	}
+	if event.Version <= 0 {
+		fields = append(fields, apperrors.FieldError{Field: "version", Message: "must be positive"})
+	}
+	if event.OrderUID == "" && event.EventType != EventOrderCreated {
+		fields = append(fields, apperrors.FieldError{Field: "order_uid", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return nil, errors.Wrap(apperrors.NewValidationError(fields...), "invalid order event")
+	}
+	return &event, nil
+}
+
+// order разбирает заказ из события order.created
+func (e *orderEvent) order() (*entities.Order, error) {
+	var order entities.Order
+	if err := json.Unmarshal(e.Payload, &order); err != nil {
+		return nil, errors.Wrap(apperrors.Validation(err), "failed to unmarshal order")
+	}
+	if e.OrderUID != "" && e.OrderUID != order.OrderUID {
+		return nil, apperrors.NewValidationError(apperrors.FieldError{Field: "order_uid", Message: "does not match payload"})
+	}
+	order.Version = e.Version
+	return &order, nil
+}
+
+// update разбирает изменение из события order.updated
+func (e *orderEvent) update() (*entities.OrderUpdate, error) {
+	var update entities.OrderUpdate
+	if err := json.Unmarshal(e.Payload, &update); err != nil {
+		return nil, errors.Wrap(apperrors.Validation(err), "failed to unmarshal order update")
+	}
+	update.OrderUID = e.OrderUID
+	update.Version = e.Version
+	return &update, nil
+}
+
+// applyEvent выполняет событие в соответствии с его типом
+func (uc *orderUseCase) applyEvent(ctx context.Context, event *orderEvent) error {
+	switch event.EventType {
+	case EventOrderUpdated:
+		update, err := event.update()
+		if err != nil {
+			return err
+		}
+		return uc.applyUpdate(ctx, update)
+	case EventOrderCancelled:
+		var payload cancellation
+		if len(event.Payload) > 0 {
+			if err := json.Unmarshal(event.Payload, &payload); err != nil {
+				return errors.Wrap(apperrors.Validation(err), "failed to unmarshal cancellation")
+			}
+		}
+		return uc.cancelOrder(ctx, event.OrderUID, event.Version, payload.Reason)
+	default:
+		order, err := event.order()
+		if err != nil {
+			return err
+		}
+		return uc.CreateOrder(ctx, order)
+	}
+}
+
+// applyUpdate проверяет и применяет частичное изменение заказа, после чего удаляет
+// заказ из кэша: следующее чтение загрузит его из базы целиком
+func (uc *orderUseCase) applyUpdate(ctx context.Context, update *entities.OrderUpdate) error {
+	if err := uc.validateUpdate(update); err != nil {
+		return errors.Wrap(err, "order update validation failed")
+	}
+	if err := uc.orderRepo.ApplyUpdate(ctx, *update); err != nil {
+		return errors.Wrap(err, "failed to apply order update")
+	}
+	uc.cache.Delete(update.OrderUID)
+	return nil
+}
+
+func (uc *orderUseCase) validateUpdate(update *entities.OrderUpdate) error {
+	if update.Empty() {
+		return apperrors.NewValidationError(apperrors.FieldError{Field: "payload", Message: "must change at least one field"})
+	}
+	if err := uc.validator.ValidateStruct(update); err != nil {
+		return err
+	}
+
+	var fields []apperrors.FieldError
+	if update.TrackNumber != nil && *update.TrackNumber == "" {
+		fields = append(fields, apperrors.FieldError{Field: "track_number", Message: "is required"})
+	}
+	if update.DeliveryService != nil && *update.DeliveryService == "" {
+		fields = append(fields, apperrors.FieldError{Field: "delivery_service", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return apperrors.NewValidationError(fields...)
+	}
+	return nil
+}
+
+// cancelOrder переводит заказ в статус cancelled, если переход разрешен
+func (uc *orderUseCase) cancelOrder(ctx context.Context, orderUID string, version int64, reason string) error {
+	order, err := uc.orderRepo.GetByUID(ctx, orderUID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get order from database")
+	}
+	// Версию проверяем до статуса, чтобы повторная отмена считалась устаревшей, а не запрещенной
+	if version <= order.Version {
+		return errors.Wrapf(apperrors.ErrStaleVersion, "order %s: event version %d, stored version %d",
+			orderUID, version, order.Version)
+	}
+	if !order.Status.CanTransitionTo(entities.StatusCancelled) {
+		return errors.Wrapf(apperrors.ErrInvalidTransition, "%s -> %s", order.Status, entities.StatusCancelled)
+	}
+
+	change := entities.OrderStatusChange{
+		OrderUID:  orderUID,
+		From:      order.Status,
+		To:        entities.StatusCancelled,
+		Reason:    reason,
+		ChangedAt: statusTime(time.Now()),
+	}
+	update := entities.OrderUpdate{OrderUID: orderUID, Version: version, StatusChange: &change}
+	if err := uc.orderRepo.ApplyUpdate(ctx, update); err != nil {
+		return errors.Wrap(err, "failed to cancel order")
+	}
+	uc.cache.Delete(orderUID)
+	uc.publishStatusChange(ctx, change)
+	return nil
+}
+
+// skipStale подавляет ошибку устаревшего события, оставляя запись в логе
+func skipStale(err error) error {
+	if errors.Is(err, apperrors.ErrStaleVersion) {
+		log.Printf("Skipping stale order event: %v", err)
+		return nil
+	}
+	return err
+}
@@ -2,24 +2,44 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
+	"log"
+	"order-service0/internal/domain/apperrors"
 	"order-service0/internal/domain/entities"
 	"order-service0/internal/pkg/validator"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 )
 
+// lookupTimeout ограничивает общий запрос к базе, который singleflight выполняет
+// для всех ожидающих одного заказа
+const lookupTimeout = 5 * time.Second
+
+// publishTimeout ограничивает публикацию события смены статуса
+const publishTimeout = 5 * time.Second
+
 type orderUseCase struct {
 	orderRepo OrderRepository
 	cache     Cache
 	validator *validator.CustomValidator
+	// lookups объединяет одновременные промахи кэша по одному order_uid в один запрос к базе
+	lookups  singleflight.Group
+	notFound *negativeCache
+	// statusEvents может быть nil, тогда события смены статуса не публикуются
+	statusEvents StatusEventPublisher
 }
 
-func NewOrderUseCase(orderRepo OrderRepository, cache Cache) OrderUseCase {
+// NewOrderUseCase создает бизнес-логику заказов. notFoundTTL задает, сколько помнить
+// отсутствующие в базе order_uid; значение 0 отключает негативное кэширование.
+func NewOrderUseCase(orderRepo OrderRepository, cache Cache, notFoundTTL time.Duration, statusEvents StatusEventPublisher) OrderUseCase {
 	return &orderUseCase{
-		orderRepo: orderRepo,
-		cache:     cache,
-		validator: validator.NewValidator(),
+		orderRepo:    orderRepo,
+		cache:        cache,
+		validator:    validator.NewValidator(),
+		notFound:     newNegativeCache(notFoundTTL),
+		statusEvents: statusEvents,
 	}
 }
 
@@ -28,6 +48,7 @@ func (uc *orderUseCase) CreateOrder(ctx context.Context, order *entities.Order)
 	if err := uc.validator.ValidateStruct(order); err != nil {
 		return errors.Wrap(err, "order validation failed")
 	}
+	initStatus(order, time.Now())
 
 	// Сохранение в базу данных
 	if err := uc.orderRepo.Create(ctx, order); err != nil {
@@ -36,31 +57,324 @@ func (uc *orderUseCase) CreateOrder(ctx context.Context, order *entities.Order)
 
 	// Кэширование заказа
 	uc.cache.Set(order.OrderUID, order)
+	uc.notFound.remove(order.OrderUID)
 	return nil
 }
 
+func (uc *orderUseCase) SubmitOrder(ctx context.Context, order *entities.Order, idempotencyKey string) (bool, error) {
+	if err := uc.validator.ValidateStruct(order); err != nil {
+		return false, errors.Wrap(err, "order validation failed")
+	}
+	initStatus(order, time.Now())
+	order.Version = 0
+
+	replayed, err := uc.orderRepo.Insert(ctx, order, idempotencyKey)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to save order to database")
+	}
+
+	uc.cache.Set(order.OrderUID, order)
+	uc.notFound.remove(order.OrderUID)
+	return replayed, nil
+}
+
 func (uc *orderUseCase) GetOrderByUID(ctx context.Context, orderUID string) (*entities.Order, error) {
 	// Поиск в кэше
 	if order, exists := uc.cache.Get(orderUID); exists {
 		return order, nil
 	}
 
-	// Поиск в базе данных
+	// Недавно искали и не нашли
+	if uc.notFound.contains(orderUID) {
+		return nil, errors.Wrapf(apperrors.ErrOrderNotFound, "order %s", orderUID)
+	}
+
+	// Поиск в базе данных, одновременные запросы одного заказа выполняются один раз
+	result, err, _ := uc.lookups.Do("uid:"+orderUID, func() (interface{}, error) {
+		lookupCtx, cancel := sharedLookupContext(ctx)
+		defer cancel()
+		order, err := uc.orderRepo.GetByUID(lookupCtx, orderUID)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrOrderNotFound) {
+				uc.notFound.add(orderUID)
+			}
+			return nil, err
+		}
+
+		// Сохранение в кэш для будущих запросов
+		uc.cache.Set(orderUID, order)
+		return order, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get order from database")
+	}
+	// Результат общий для всех ожидавших запросов, каждый получает свою копию
+	return result.(*entities.Order).Clone(), nil
+}
+
+func (uc *orderUseCase) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*entities.Order, error) {
+	return uc.getOrderBy(ctx, "track:"+trackNumber,
+		func() (*entities.Order, bool) { return uc.cache.GetByTrackNumber(trackNumber) },
+		func(ctx context.Context) (*entities.Order, error) {
+			return uc.orderRepo.GetByTrackNumber(ctx, trackNumber)
+		},
+	)
+}
+
+func (uc *orderUseCase) GetOrderByTransaction(ctx context.Context, transaction string) (*entities.Order, error) {
+	return uc.getOrderBy(ctx, "transaction:"+transaction,
+		func() (*entities.Order, bool) { return uc.cache.GetByTransaction(transaction) },
+		func(ctx context.Context) (*entities.Order, error) {
+			return uc.orderRepo.GetByTransaction(ctx, transaction)
+		},
+	)
+}
+
+// getOrderBy ищет заказ по вторичному ключу сначала в кэше, затем в базе; одновременные
+// запросы с одним lookupKey выполняются один раз, найденный заказ кэшируется
+func (uc *orderUseCase) getOrderBy(ctx context.Context, lookupKey string, fromCache func() (*entities.Order, bool), fromRepo func(context.Context) (*entities.Order, error)) (*entities.Order, error) {
+	if order, exists := fromCache(); exists {
+		return order, nil
+	}
+
+	result, err, _ := uc.lookups.Do(lookupKey, func() (interface{}, error) {
+		lookupCtx, cancel := sharedLookupContext(ctx)
+		defer cancel()
+		order, err := fromRepo(lookupCtx)
+		if err != nil {
+			return nil, err
+		}
+		uc.cache.Set(order.OrderUID, order)
+		return order, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get order from database")
+	}
+	return result.(*entities.Order).Clone(), nil
+}
+
+// sharedLookupContext возвращает контекст для запроса, общего для нескольких вызывающих:
+// отмена запроса первого из них не должна прерывать поиск для остальных
+func sharedLookupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), lookupTimeout)
+}
+
+// ProcessOrderMessage применяет событие заказа. Устаревшие по версии события
+// отклоняются без ошибки, чтобы повторная доставка не попадала в DLQ.
+func (uc *orderUseCase) ProcessOrderMessage(ctx context.Context, message []byte) error {
+	event, err := parseOrderEvent(message)
+	if err != nil {
+		return err
+	}
+	return skipStale(uc.applyEvent(ctx, event))
+}
+
+// ProcessOrderBatch сохраняет новые заказы пачками. Изменения и отмены применяются по одному
+// после сохранения всех предшествующих им новых заказов, чтобы сохранить порядок событий.
+func (uc *orderUseCase) ProcessOrderBatch(ctx context.Context, messages []OrderMessage) (map[int]error, error) {
+	rejected := make(map[int]error)
+	var pending []*entities.Order
+	sources := make(map[string]entities.ChangeSource)
+	for i, message := range messages {
+		event, err := parseOrderEvent(message.Value)
+		if err == nil && event.EventType == EventOrderCreated {
+			var order *entities.Order
+			if order, err = event.order(); err == nil {
+				if err = uc.validator.ValidateStruct(order); err == nil {
+					pending = append(pending, order)
+					sources[order.OrderUID] = message.Source
+					continue
+				}
+				err = errors.Wrap(err, "order validation failed")
+			}
+		}
+		if err != nil {
+			rejected[i] = err
+			continue
+		}
+
+		if err := uc.saveBatch(ctx, pending, sources); err != nil {
+			return rejected, err
+		}
+		pending = nil
+		sources = make(map[string]entities.ChangeSource)
+		if err := skipStale(uc.applyEvent(entities.WithChangeSource(ctx, message.Source), event)); err != nil {
+			if IsTemporary(err) {
+				return rejected, err
+			}
+			rejected[i] = err
+		}
+	}
+
+	return rejected, uc.saveBatch(ctx, pending, sources)
+}
+
+// saveBatch сохраняет уже проверенные новые заказы одной транзакцией; sources задает
+// источник каждого заказа для истории версий
+func (uc *orderUseCase) saveBatch(ctx context.Context, orders []*entities.Order, sources map[string]entities.ChangeSource) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ctx = entities.WithOrderSources(ctx, sources)
+	now := time.Now()
+	for _, order := range orders {
+		initStatus(order, now)
+	}
+
+	// Сохранение пачки в базу данных
+	if err := uc.orderRepo.CreateBatch(ctx, orders); err != nil {
+		return errors.Wrap(err, "failed to save order batch to database")
+	}
+
+	// Кэширование заказов
+	for _, order := range orders {
+		uc.cache.Set(order.OrderUID, order)
+		uc.notFound.remove(order.OrderUID)
+	}
+	return nil
+}
+
+func (uc *orderUseCase) ChangeOrderStatus(ctx context.Context, orderUID string, status entities.OrderStatus, reason string) (*entities.Order, error) {
+	if !status.Valid() {
+		return nil, apperrors.NewValidationError(apperrors.FieldError{Field: "status", Message: "unknown status " + strconv.Quote(string(status))})
+	}
+
+	// Текущий статус читается из базы, а не из кэша, который может отставать
 	order, err := uc.orderRepo.GetByUID(ctx, orderUID)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get order from database")
 	}
+	if !order.Status.CanTransitionTo(status) {
+		return nil, errors.Wrapf(apperrors.ErrInvalidTransition, "%s -> %s", order.Status, status)
+	}
 
-	// Сохранение в кэш для будущих запросов
+	change := entities.OrderStatusChange{
+		OrderUID:  orderUID,
+		From:      order.Status,
+		To:        status,
+		Reason:    reason,
+		ChangedAt: statusTime(time.Now()),
+	}
+	if err := uc.orderRepo.UpdateStatus(ctx, change); err != nil {
+		return nil, errors.Wrap(err, "failed to update order status")
+	}
+	order.Status, order.StatusChangedAt = change.To, change.ChangedAt
 	uc.cache.Set(orderUID, order)
+	uc.publishStatusChange(ctx, change)
 	return order, nil
 }
 
-func (uc *orderUseCase) ProcessOrderMessage(ctx context.Context, message []byte) error {
-	var order entities.Order
-	if err := json.Unmarshal(message, &order); err != nil {
-		return errors.Wrap(err, "failed to unmarshal order message")
+// publishStatusChange публикует событие смены статуса. Статус к этому моменту уже
+// сохранен, поэтому ошибка публикации только логируется, а отмена ctx (например,
+// клиент отключился) не должна отменять публикацию.
+func (uc *orderUseCase) publishStatusChange(ctx context.Context, change entities.OrderStatusChange) {
+	if uc.statusEvents == nil {
+		return
+	}
+	publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+	defer cancel()
+	if err := uc.statusEvents.PublishStatusChange(publishCtx, change); err != nil {
+		log.Printf("Failed to publish status change of order %s: %v", change.OrderUID, err)
+	}
+}
+
+// initStatus задает статус нового заказа, отбрасывая пришедший от отправителя
+func initStatus(order *entities.Order, now time.Time) {
+	order.Status = entities.StatusCreated
+	order.StatusChangedAt = statusTime(now)
+}
+
+// statusTime приводит время к точности колонки TIMESTAMP, чтобы кэш совпадал с базой
+func statusTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Размер страницы поиска заказов
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+func (uc *orderUseCase) SearchOrders(ctx context.Context, filter entities.OrderFilter) (*entities.OrderPage, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Sort == "" {
+		filter.Sort = entities.SortDesc
+	}
+
+	var fields []apperrors.FieldError
+	if filter.Limit < 0 || filter.Limit > maxSearchLimit {
+		fields = append(fields, apperrors.FieldError{Field: "limit", Message: "must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+	}
+	if filter.Sort != entities.SortDesc && filter.Sort != entities.SortAsc {
+		fields = append(fields, apperrors.FieldError{Field: "sort", Message: "must be one of asc, desc"})
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && filter.CreatedFrom.After(filter.CreatedTo) {
+		fields = append(fields, apperrors.FieldError{Field: "created_from", Message: "must not be after created_to"})
 	}
+	if len(fields) > 0 {
+		return nil, apperrors.NewValidationError(fields...)
+	}
+
+	page, err := uc.orderRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search orders")
+	}
+	return page, nil
+}
+
+func (uc *orderUseCase) GetOrderHistory(ctx context.Context, orderUID string) ([]entities.OrderVersion, error) {
+	history, err := uc.orderRepo.GetHistory(ctx, orderUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get order history")
+	}
+	return history, nil
+}
+
+func (uc *orderUseCase) GetOrderVersion(ctx context.Context, orderUID string, number int) (*entities.OrderVersion, error) {
+	version, err := uc.orderRepo.GetVersion(ctx, orderUID, number)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get order version")
+	}
+	return version, nil
+}
 
-	return uc.CreateOrder(ctx, &order)
+func (uc *orderUseCase) DeleteOrder(ctx context.Context, orderUID string) error {
+	if err := uc.orderRepo.Delete(ctx, orderUID, statusTime(time.Now())); err != nil {
+		return errors.Wrap(err, "failed to delete order")
+	}
+	uc.cache.Delete(orderUID)
+	uc.notFound.add(orderUID)
+	return nil
+}
+
+func (uc *orderUseCase) EraseCustomer(ctx context.Context, customerID, requestedBy, reason string) (*entities.CustomerErasure, error) {
+	var fields []apperrors.FieldError
+	if customerID == "" {
+		fields = append(fields, apperrors.FieldError{Field: "customer_id", Message: "is required"})
+	}
+	if requestedBy == "" {
+		fields = append(fields, apperrors.FieldError{Field: "requested_by", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return nil, apperrors.NewValidationError(fields...)
+	}
+
+	erasure := &entities.CustomerErasure{
+		CustomerID:  customerID,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+		Source:      entities.ChangeSourceFor(ctx, ""),
+		RequestedAt: statusTime(time.Now()),
+	}
+	if err := uc.orderRepo.EraseCustomer(ctx, erasure); err != nil {
+		return nil, errors.Wrap(err, "failed to erase customer data")
+	}
+
+	// В кэше остались бы исходные персональные данные
+	for _, orderUID := range erasure.OrderUIDs {
+		uc.cache.Delete(orderUID)
+	}
+	return erasure, nil
 }
@@ -0,0 +1,13 @@
+-- Индексы для поиска заказов (GET /orders). Выборка всегда сортируется по
+-- (date_created, order_uid), поэтому селективные фильтры по orders идут в паре с ключом сортировки.
+CREATE INDEX IF NOT EXISTS idx_orders_customer_date ON orders(customer_id, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service_date ON orders(delivery_service, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_locale_date ON orders(locale, date_created DESC, order_uid DESC);
+
+CREATE INDEX IF NOT EXISTS idx_payments_provider ON payments(provider);
+CREATE INDEX IF NOT EXISTS idx_payments_bank ON payments(bank);
+CREATE INDEX IF NOT EXISTS idx_payments_currency ON payments(currency);
+
+CREATE INDEX IF NOT EXISTS idx_items_brand_nm_id ON items(brand, nm_id);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items(nm_id);
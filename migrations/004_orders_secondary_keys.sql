@@ -0,0 +1,5 @@
+-- Поиск заказа по трек-номеру и транзакции оплаты возвращает самый новый заказ,
+-- поэтому индекс по трек-номеру включает ключ сортировки
+DROP INDEX IF EXISTS idx_orders_track_number;
+CREATE INDEX IF NOT EXISTS idx_orders_track_number_date ON orders(track_number, date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments(transaction);
@@ -0,0 +1,16 @@
+-- Статус заказа и история его переходов
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP;
+UPDATE orders SET status_changed_at = date_created WHERE status_changed_at IS NULL;
+ALTER TABLE orders ALTER COLUMN status_changed_at SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMP NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history(order_uid, changed_at);
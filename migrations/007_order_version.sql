@@ -0,0 +1,2 @@
+-- Версия последнего примененного события заказа для отклонения устаревших обновлений
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- Неизменяемая история версий заказа: принятые данные, источник, снимок и отличия от предыдущей версии
+CREATE TABLE IF NOT EXISTS order_versions (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    number INT NOT NULL,
+    change VARCHAR(20) NOT NULL,
+    source JSONB NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    payload JSONB NOT NULL,
+    snapshot JSONB NOT NULL,
+    diff JSONB NOT NULL,
+    UNIQUE (order_uid, number)
+    );
@@ -0,0 +1,16 @@
+-- Мягкое удаление заказов и аудит удаления персональных данных покупателей
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+-- Заказы со стертыми персональными данными не перезаписываются повторной доставкой
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS customer_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id VARCHAR(255) NOT NULL,
+    requested_by VARCHAR(255) NOT NULL,
+    reason TEXT,
+    source JSONB NOT NULL,
+    requested_at TIMESTAMP NOT NULL,
+    order_uids TEXT[] NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_customer_erasures_customer ON customer_erasures(customer_id, requested_at);
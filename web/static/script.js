@@ -35,20 +35,26 @@ function displayOrder(order) {
     document.getElementById('orderBasic').innerHTML = `
         <p><strong>Order UID:</strong> ${order.order_uid}</p>
         <p><strong>Track Number:</strong> ${order.track_number}</p>
+        <p><strong>Status:</strong> ${order.status}</p>
+        ${order.customer_id === undefined ? '' : `
         <p><strong>Entry:</strong> ${order.entry}</p>
         <p><strong>Customer ID:</strong> ${order.customer_id}</p>
         <p><strong>Delivery Service:</strong> ${order.delivery_service}</p>
-        <p><strong>Date Created:</strong> ${new Date(order.date_created).toLocaleString()}</p>
+        <p><strong>Date Created:</strong> ${new Date(order.date_created).toLocaleString()}</p>`}
     `;
 
-    document.getElementById('deliveryInfo').innerHTML = `
+    document.getElementById('deliveryInfo').innerHTML = order.delivery.name === undefined ? `
+        <p><strong>City:</strong> ${order.delivery.city}</p>
+    ` : `
         <p><strong>Name:</strong> ${order.delivery.name}</p>
         <p><strong>Phone:</strong> ${order.delivery.phone}</p>
         <p><strong>Email:</strong> ${order.delivery.email}</p>
         <p><strong>Address:</strong> ${order.delivery.city}, ${order.delivery.address}, ${order.delivery.region} ${order.delivery.zip}</p>
     `;
 
-    document.getElementById('paymentInfo').innerHTML = `
+    document.getElementById('paymentInfo').innerHTML = !order.payment ? `
+        <p>Not available</p>
+    ` : `
         <p><strong>Transaction:</strong> ${order.payment.transaction}</p>
         <p><strong>Amount:</strong> $${(order.payment.amount / 100).toFixed(2)}</p>
         <p><strong>Currency:</strong> ${order.payment.currency}</p>